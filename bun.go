@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// defaultBunDownloadURL is the release asset URL template of Bun, {version} and {platform} are substituted.
+// The SHASUMS256.txt of the release is expected next to the asset.
+const defaultBunDownloadURL = "https://github.com/oven-sh/bun/releases/download/bun-v{version}/bun-{platform}.zip"
+
+// bunLockfiles are the lockfiles of Bun, the text based bun.lock replaces the binary bun.lockb since Bun 1.2
+var bunLockfiles = []string{"bun.lock", "bun.lockb"}
+
+// bunManager runs the command with Bun, downloaded from the release assets
+type bunManager struct {
+	toolProvisioner
+	req  versionRequirement
+	dist bunDistClient
+}
+
+func newBunManager(p toolProvisioner, strict bool, downloadURL string) (bunManager, error) {
+	req, err := packageManagerRequirement(p.workdir, "bun", strict)
+	if err != nil {
+		return bunManager{}, err
+	}
+	return bunManager{toolProvisioner: p, req: req, dist: newBunDistClient(downloadURL)}, nil
+}
+
+func (bunManager) Name() string {
+	return "bun"
+}
+
+// Setup uses the preinstalled Bun if it satisfies the requirement, otherwise installs the highest Bun release satisfying it.
+// Bun is also published to the npm registry, it is used to resolve version ranges and dist-tags.
+func (m bunManager) Setup() error {
+	if m.req.Hash != "" {
+		// the hash of the packageManager field is of the npm package, Bun is installed from the release archive instead
+		log.Warnf("The %s hash of the packageManager field is not verified for bun, the release archive is verified against its SHASUMS256.txt instead", m.req.HashAlgorithm)
+	}
+
+	installedVer, err := toolVersion("bun", m.workdir)
+	if err != nil {
+		return err
+	}
+
+	spec := m.req.Spec
+	if installedVer != "" {
+		log.Printf("Preinstalled bun version: %s", installedVer)
+
+		if spec == "" {
+			log.Donef("No bun version requirement found, using the preinstalled bun")
+			return nil
+		}
+		satisfied, err := versionSatisfies(installedVer, spec)
+		if err != nil {
+			log.Warnf("Failed to compare preinstalled bun version with `%s`: %s", spec, err)
+		} else if satisfied {
+			log.Donef("Preinstalled bun version %s satisfies `%s`, skipping bun install", installedVer, spec)
+			return nil
+		}
+	} else if spec == "" {
+		spec = "latest"
+	}
+
+	ver, err := resolveWithToolCache(m.tools, "bun", spec, func(spec string) (string, error) {
+		return m.registry.resolveVersion("bun", spec)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve bun version `%s`: %s", spec, err)
+	}
+	log.Printf("Installing bun version %s", ver)
+
+	binDir, err := installBun(m.dist, m.tools, ver)
+	if err != nil {
+		return fmt.Errorf("failed to install bun %s: %s", ver, err)
+	}
+	if err := prependPath(binDir); err != nil {
+		return fmt.Errorf("failed to add %s to PATH: %s", binDir, err)
+	}
+	log.Printf("Added %s to PATH", binDir)
+	return nil
+}
+
+func (bunManager) Command(npmArgs []string) []string {
+	return append([]string{"bun"}, bunArgs(npmArgs)...)
+}
+
+// Cache marks the Bun global install cache for caching, with the Bun lockfile as the indicator
+func (m bunManager) Cache() error {
+	cmd := command.New("bun", "pm", "cache")
+	cmd.SetDir(m.workdir)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get bun cache directory: %s", out)
+	}
+
+	lockfile := filepath.Join(m.workdir, bunLockfiles[0])
+	for _, name := range bunLockfiles {
+		pth := filepath.Join(m.workdir, name)
+		if exists, err := pathutil.IsPathExists(pth); err == nil && exists {
+			lockfile = pth
+			break
+		}
+	}
+
+	return cacheDependencyPaths([]string{out}, lockfile)
+}
+
+// bunArgs translates npm command arguments to the equivalent Bun arguments.
+// bun test runs the Bun test runner, so npm test and npm start are translated to bun run.
+// https://bun.sh/docs/cli/install
+func bunArgs(npmArgs []string) []string {
+	if len(npmArgs) == 0 {
+		return []string{"install"}
+	}
+
+	cmd, rest := npmArgs[0], npmArgs[1:]
+	switch cmd {
+	case "install", "isntall", "i", "add":
+		var args []string
+		hasPkgs := false
+		for i := 0; i < len(rest); i++ {
+			arg := rest[i]
+			switch {
+			case arg == "-S" || arg == "--save" || arg == "-P" || arg == "--save-prod":
+				// bun add saves to dependencies by default
+				continue
+			case arg == "--save-dev":
+				arg = "--dev"
+			case arg == "-D":
+				arg = "-d"
+			case arg == "-O" || arg == "--save-optional":
+				arg = "--optional"
+			case arg == "-E" || arg == "--save-exact":
+				arg = "--exact"
+			case !strings.HasPrefix(arg, "-"):
+				hasPkgs = true
+			case i+1 < len(rest) && sliceutil.IsStringInSlice(arg, npmValueFlags):
+				args = append(args, arg)
+				i++
+				arg = rest[i]
+			}
+			args = append(args, arg)
+		}
+
+		if hasPkgs {
+			return append([]string{"add"}, args...)
+		}
+		return append([]string{"install"}, args...)
+	case "ci":
+		return append([]string{"install", "--frozen-lockfile"}, rest...)
+	case "run", "run-script", "rum", "urn":
+		return append([]string{"run"}, withoutScriptArgsSeparator(rest)...)
+	case "test", "t", "tst":
+		return append([]string{"run"}, withoutScriptArgsSeparator(append([]string{"test"}, rest...))...)
+	case "start":
+		return append([]string{"run"}, withoutScriptArgsSeparator(append([]string{"start"}, rest...))...)
+	case "uninstall", "rm", "r", "un":
+		return append([]string{"remove"}, rest...)
+	case "upgrade", "up":
+		return append([]string{"update"}, rest...)
+	}
+	return npmArgs
+}
+
+// bunDistClient downloads the Bun release assets
+type bunDistClient struct {
+	urlTemplate string
+	client      *http.Client
+}
+
+func newBunDistClient(urlTemplate string) bunDistClient {
+	if urlTemplate == "" {
+		urlTemplate = defaultBunDownloadURL
+	}
+	return bunDistClient{
+		urlTemplate: urlTemplate,
+		client:      &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// bunPlatform returns the platform name used in the Bun release asset names, like darwin-aarch64 or linux-x64
+func bunPlatform() (string, error) {
+	switch runtime.GOOS {
+	case "darwin", "linux":
+	default:
+		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		return runtime.GOOS + "-x64", nil
+	case "arm64":
+		return runtime.GOOS + "-aarch64", nil
+	}
+	return "", fmt.Errorf("unsupported architecture: %s", runtime.GOARCH)
+}
+
+// assetURL returns the download URL of the Bun release asset for the version and platform
+func (c bunDistClient) assetURL(version, platform string) string {
+	return strings.NewReplacer("{version}", version, "{platform}", platform).Replace(c.urlTemplate)
+}
+
+// download downloads and verifies the Bun release asset of the version into dir
+func (c bunDistClient) download(version, platform, dir string) (string, error) {
+	u := c.assetURL(version, platform)
+	i := strings.LastIndex(u, "/")
+	if i == -1 {
+		return "", fmt.Errorf("invalid bun download URL: %s", u)
+	}
+	filename := u[i+1:]
+
+	shasumsPth := filepath.Join(dir, "SHASUMS256.txt")
+	if err := downloadFile(c.client, u[:i+1]+"SHASUMS256.txt", shasumsPth); err != nil {
+		return "", err
+	}
+	shasums, err := fileutil.ReadStringFromFile(shasumsPth)
+	if err != nil {
+		return "", err
+	}
+	checksum, err := parseShasums(shasums, filename)
+	if err != nil {
+		return "", err
+	}
+
+	pth := filepath.Join(dir, filename)
+	if err := downloadFile(c.client, u, pth); err != nil {
+		return "", err
+	}
+	if err := verifyFileHash(pth, "sha256", checksum); err != nil {
+		return "", err
+	}
+	log.Printf("Verified SHA-256 checksum of %s", filename)
+
+	return pth, nil
+}
+
+// installBun installs the Bun release into the tool cache, unless it is already there, and returns its bin directory
+func installBun(dist bunDistClient, tools toolCache, version string) (string, error) {
+	platform, err := bunPlatform()
+	if err != nil {
+		return "", err
+	}
+	binDir := filepath.Join(tools.path("bun", version), "bun-"+platform)
+
+	if tools.isComplete("bun", version) {
+		log.Printf("Using cached bun %s", version)
+		return binDir, nil
+	}
+
+	dir, err := tools.prepare("bun", version)
+	if err != nil {
+		return "", err
+	}
+	archive, err := dist.download(version, platform, dir)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := command.New("unzip", "-o", "-q", archive, "-d", dir)
+	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return "", fmt.Errorf("unzip command failed: %s", out)
+		}
+		return "", fmt.Errorf("error running unzip command: %s", err)
+	}
+
+	if err := os.Remove(archive); err != nil {
+		log.Warnf("Failed to remove %s: %s", archive, err)
+	}
+	if err := tools.markComplete("bun", version); err != nil {
+		return "", err
+	}
+	return binDir, nil
+}
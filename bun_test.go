@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestBunArgs(t *testing.T) {
+	testCases := []struct {
+		npmArgs []string
+		want    []string
+	}{
+		{nil, []string{"install"}},
+		{[]string{"ci"}, []string{"install", "--frozen-lockfile"}},
+		{[]string{"install", "--save", "zod"}, []string{"add", "zod"}},
+		{[]string{"install", "-D", "typescript", "--save-exact"}, []string{"add", "-d", "typescript", "--exact"}},
+		{[]string{"run", "build", "--", "--watch"}, []string{"run", "build", "--watch"}},
+		{[]string{"test"}, []string{"run", "test"}},
+		{[]string{"test", "--", "--coverage"}, []string{"run", "test", "--coverage"}},
+		{[]string{"start"}, []string{"run", "start"}},
+		{[]string{"uninstall", "zod"}, []string{"remove", "zod"}},
+		{[]string{"upgrade"}, []string{"update"}},
+	}
+
+	for _, tc := range testCases {
+		if got := bunArgs(tc.npmArgs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("bunArgs(%v) = %v, want %v", tc.npmArgs, got, tc.want)
+		}
+	}
+}
+
+func TestBunDistClientAssetURL(t *testing.T) {
+	testCases := []struct {
+		template string
+		want     string
+	}{
+		{"", "https://github.com/oven-sh/bun/releases/download/bun-v1.1.34/bun-linux-x64.zip"},
+		{"https://mirror.example.com/bun/{version}/bun-{platform}.zip", "https://mirror.example.com/bun/1.1.34/bun-linux-x64.zip"},
+	}
+
+	for _, tc := range testCases {
+		if got := newBunDistClient(tc.template).assetURL("1.1.34", "linux-x64"); got != tc.want {
+			t.Errorf("assetURL(%s) = %s, want %s", tc.template, got, tc.want)
+		}
+	}
+}
+
+func TestBunDistClientDownload(t *testing.T) {
+	asset := []byte("bun release")
+	checksum := fmt.Sprintf("%x", sha256.Sum256(asset))
+	shasums := map[string]string{
+		"/ok/SHASUMS256.txt":       checksum + "  bun-linux-x64.zip\n",
+		"/mismatch/SHASUMS256.txt": fmt.Sprintf("%x  bun-linux-x64.zip\n", sha256.Sum256([]byte("other"))),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := shasums[r.URL.Path]; ok {
+			if _, err := fmt.Fprint(w, content); err != nil {
+				t.Errorf("failed to write response: %s", err)
+			}
+			return
+		}
+		if _, err := w.Write(asset); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		dir  string
+		hasE bool
+	}{
+		{"ok", false},
+		{"mismatch", true},
+	}
+
+	for _, tc := range testCases {
+		client := newBunDistClient(server.URL + "/" + tc.dir + "/bun-{platform}.zip")
+		_, err := client.download("1.1.34", "linux-x64", t.TempDir())
+		if tc.hasE != (err != nil) {
+			t.Errorf("download(%s) error = %v, want error: %v", tc.dir, err, tc.hasE)
+		}
+	}
+}
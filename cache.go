@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"github.com/bitrise-io/go-steputils/cache"
+	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
 )
 
@@ -30,3 +31,43 @@ func cacheNpm(workdir string) error {
 	}
 	return nil
 }
+
+// cacheDependencyPaths marks the existing paths for caching, with the lockfile as the cache indicator
+func cacheDependencyPaths(paths []string, lockfile string) error {
+	depsCache := cache.New()
+	for _, pth := range paths {
+		exist, err := pathutil.IsPathExists(pth)
+		if err != nil {
+			return fmt.Errorf("failed to check path existence, error: %s", err)
+		}
+		if !exist {
+			log.Printf("Skipping non-existent path: %s", pth)
+			continue
+		}
+		depsCache.IncludePath(pth + " -> " + lockfile)
+	}
+
+	if err := depsCache.Commit(); err != nil {
+		return fmt.Errorf("failed to mark paths to be cached, error: %s", err)
+	}
+	return nil
+}
+
+// cacheTools marks the tool cache directory for caching
+func cacheTools(tools toolCache) error {
+	exist, err := pathutil.IsDirExists(tools.root)
+	if err != nil {
+		return fmt.Errorf("failed to check directory existence, error: %s", err)
+	}
+	if !exist {
+		return nil
+	}
+
+	toolsCache := cache.New()
+	toolsCache.IncludePath(tools.root)
+
+	if err := toolsCache.Commit(); err != nil {
+		return fmt.Errorf("failed to mark tool cache directory to be cached, error: %s", err)
+	}
+	return nil
+}
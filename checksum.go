@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+}
+
+// fileHash returns the hex encoded digest of the file
+func fileHash(pth, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(pth)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Warnf("Failed to close %s: %s", pth, err)
+		}
+	}()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %s", pth, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyFileHash checks the file against the expected hex encoded digest
+func verifyFileHash(pth, algorithm, expected string) error {
+	actual, err := fileHash(pth, algorithm)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%s checksum mismatch for %s: expected %s, got %s", algorithm, pth, expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyFileHash(t *testing.T) {
+	pth := filepath.Join(t.TempDir(), "file.txt")
+	if err := ioutil.WriteFile(pth, []byte("hello\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		algorithm string
+		hash      string
+		hasE      bool
+	}{
+		{"sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", false},
+		{"SHA256", "5891B5B522D5DF086D0FF0B110FBD9D21BB4FC7163AF34D08286A2E846F6BE03", false},
+		{"sha1", "f572d396fae9206628714fb2ce00f72e94f2258f", false},
+		{"sha256", "0000", true},
+		{"md5", "b1946ac92492d2347c6235b4d2611184", true},
+	}
+
+	for _, tc := range testCases {
+		err := verifyFileHash(pth, tc.algorithm, tc.hash)
+		if tc.hasE != (err != nil) {
+			t.Errorf("verifyFileHash(%s, %s) error = %v, want error: %v", tc.algorithm, tc.hash, err, tc.hasE)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/kballard/go-shellquote"
+)
+
+// installCommands are the npm commands installing the dependencies, only these are followed by caching
+// npm commands: https://github.com/npm/cli/blob/36682d4482cddee0acc55e8d75b3bee6e78fff37/lib/config/cmd-list.js
+var installCommands = []string{"install", "isntall", "i", "add", "ci"}
+
+// commandLines returns the non-empty lines of a multiline input, lines starting with # are comments
+func commandLines(input string) []string {
+	var lines []string
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseCommands parses the newline separated npm commands of the command input
+func parseCommands(input string) ([][]string, error) {
+	var commands [][]string
+	for _, line := range commandLines(input) {
+		args, err := shellquote.Split(line)
+		if err != nil {
+			return nil, fmt.Errorf("`%s` is not a valid CLI command: %s", line, err)
+		}
+		commands = append(commands, args)
+	}
+	return commands, nil
+}
+
+// parseIntents parses the newline separated intents of the intent input to npm commands
+func parseIntents(input string) ([][]string, error) {
+	var commands [][]string
+	for _, line := range commandLines(input) {
+		args, err := intentArgs(line)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, args)
+	}
+	return commands, nil
+}
+
+// hasCommand reports whether any of the npm commands is one of the given npm commands
+func hasCommand(commands [][]string, names ...string) bool {
+	for _, npmArgs := range commands {
+		if len(npmArgs) != 0 && sliceutil.IsStringInSlice(npmArgs[0], names) {
+			return true
+		}
+	}
+	return false
+}
+
+// installToCI returns the commands with the npm install commands installing the dependencies of package.json rewritten to npm ci.
+// Commands adding packages or installing globally are kept, npm ci does neither.
+func installToCI(commands [][]string) ([][]string, bool) {
+	var rewritten [][]string
+	changed := false
+	for _, npmArgs := range commands {
+		if len(npmArgs) == 0 || !sliceutil.IsStringInSlice(npmArgs[0], []string{"install", "isntall", "i"}) || !installsPackageJSON(npmArgs[1:]) {
+			rewritten = append(rewritten, npmArgs)
+			continue
+		}
+		rewritten = append(rewritten, append([]string{"ci"}, npmArgs[1:]...))
+		changed = true
+	}
+	return rewritten, changed
+}
+
+// installsPackageJSON reports whether the npm install arguments only install the dependencies of package.json
+func installsPackageJSON(args []string) bool {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-g" || arg == "--global":
+			return false
+		case !strings.HasPrefix(arg, "-"):
+			return false
+		case sliceutil.IsStringInSlice(arg, npmValueFlags):
+			i++
+		}
+	}
+	return true
+}
+
+// commandResult is the outcome of a user provided command
+type commandResult struct {
+	npmArgs  []string
+	cmdLine  string
+	duration time.Duration
+	err      error
+	skipped  bool
+	// canceled is set if the command was terminated because another command failed
+	canceled bool
+}
+
+func (r commandResult) status() string {
+	switch {
+	case r.skipped:
+		return "skipped"
+	case r.canceled:
+		return "canceled"
+	case r.err != nil:
+		return fmt.Sprintf("failed (exit code %d)", exitCode(r.err))
+	}
+	return "succeeded"
+}
+
+// newUserCommand returns the command running the npm command with the package manager in the working directory
+func newUserCommand(pm packageManager, workdir string, npmArgs []string) *command.Model {
+	cmdArgs := pm.Command(npmArgs)
+	cmd := command.New(cmdArgs[0], cmdArgs[1:]...)
+	cmd.SetStdout(stdout).SetStderr(stderr)
+	cmd.SetDir(workdir)
+	return cmd
+}
+
+// runCommand runs the npm command with the package manager in the working directory,
+// it is retried if it fails with a transient network error
+func runCommand(pm packageManager, workdir string, npmArgs []string) commandResult {
+	cmdLine := newUserCommand(pm, workdir, npmArgs).PrintableCommandArgs()
+
+	start := time.Now()
+	err := networkRetry.run(cmdLine, func() (string, error) {
+		out := newTailBuffer(outputTailSize)
+		cmd := newUserCommand(pm, workdir, npmArgs)
+		cmd.SetStdout(io.MultiWriter(stdout, out)).SetStderr(io.MultiWriter(stderr, out))
+		log.Donef("$ %s", cmdLine)
+
+		err := cmd.Run()
+		flushOutputs()
+		return out.String(), err
+	})
+	return commandResult{
+		npmArgs:  npmArgs,
+		cmdLine:  cmdLine,
+		duration: time.Since(start),
+		err:      err,
+	}
+}
+
+// runCommands runs the commands one after the other. In fail-fast mode the commands after a failed one are skipped.
+func runCommands(pm packageManager, workdir string, commands [][]string, failFast bool) []commandResult {
+	var results []commandResult
+	failed := false
+	for i, npmArgs := range commands {
+		if failed && failFast {
+			cmdLine := newUserCommand(pm, workdir, npmArgs).PrintableCommandArgs()
+			results = append(results, commandResult{npmArgs: npmArgs, cmdLine: cmdLine, skipped: true})
+			continue
+		}
+
+		fmt.Println()
+		if len(commands) > 1 {
+			log.Infof("Running user provided command (%d/%d)", i+1, len(commands))
+		} else {
+			log.Infof("Running user provided command")
+		}
+
+		result := runCommand(pm, workdir, npmArgs)
+		if result.err != nil {
+			log.Errorf("Command failed: %s", result.err)
+			failed = true
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// runUserCommands runs the commands, concurrently if parallel mode is enabled, and returns their results
+// with the wall-clock duration of the run. In parallel mode it is shorter than the total duration of the commands.
+func runUserCommands(pm packageManager, workdir string, commands [][]string, config Config) ([]commandResult, time.Duration) {
+	start := time.Now()
+	var results []commandResult
+	if config.Parallel && len(commands) > 1 {
+		results = newParallelRunner(pm, workdir, config.MaxParallel, config.FailureMode != "run-all", config.GroupOutput).run(commands)
+	} else {
+		results = runCommands(pm, workdir, commands, config.FailureMode != "run-all")
+	}
+	return results, time.Since(start)
+}
+
+// commandExitCode returns the exit code of the first failed command.
+// Canceled commands only count as failed if no other command failed.
+func commandExitCode(results []commandResult) int {
+	code, canceledCode := 0, 0
+	for _, r := range results {
+		switch {
+		case r.err == nil:
+		case r.canceled && canceledCode == 0:
+			canceledCode = 1
+		case !r.canceled && code == 0:
+			code = exitCode(r.err)
+		}
+	}
+	if code == 0 {
+		code = canceledCode
+	}
+	return code
+}
+
+// printCommandSummary prints the status and duration of the commands as a table
+func printCommandSummary(results []commandResult) {
+	fmt.Println()
+	log.Infof("Command summary")
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tCOMMAND\tSTATUS\tDURATION")
+	for i, r := range results {
+		duration := "-"
+		if !r.skipped {
+			duration = r.duration.Round(10 * time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, r.cmdLine, r.status(), duration)
+	}
+	if err := w.Flush(); err != nil {
+		log.Warnf("Failed to print command summary: %s", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCommands(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  [][]string
+		hasE  bool
+	}{
+		{"install", [][]string{{"install"}}, false},
+		{"ci\n\n# run the tests\nrun test -- --reporter \"dot\"\n", [][]string{{"ci"}, {"run", "test", "--", "--reporter", "dot"}}, false},
+		{"  install -g cordova  ", [][]string{{"install", "-g", "cordova"}}, false},
+		{"run 'unterminated", nil, true},
+		{"\n", nil, false},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseCommands(tc.input)
+		if tc.hasE != (err != nil) {
+			t.Errorf("parseCommands(%q) error = %v, want error: %v", tc.input, err, tc.hasE)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseCommands(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestParseIntents(t *testing.T) {
+	got, err := parseIntents("ci\nrun:lint\ntest")
+	if err != nil {
+		t.Fatalf("parseIntents() error = %v", err)
+	}
+	want := [][]string{{"ci"}, {"run", "lint"}, {"test"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseIntents() = %v, want %v", got, want)
+	}
+
+	if _, err := parseIntents("ci\ndeploy"); err == nil {
+		t.Errorf("parseIntents() should fail for an unknown intent")
+	}
+}
+
+func TestHasCommand(t *testing.T) {
+	commands := [][]string{{"ci"}, {"run", "install"}}
+	if !hasCommand(commands, installCommands...) {
+		t.Errorf("hasCommand(%v, install commands) = false, want true", commands)
+	}
+	if hasCommand(commands, "install") {
+		t.Errorf("hasCommand(%v, install) = true, want false", commands)
+	}
+}
+
+// shellManager runs the first npm argument as a shell command, for testing the command runner
+type shellManager struct{}
+
+func (shellManager) Name() string                      { return "sh" }
+func (shellManager) Setup() error                      { return nil }
+func (shellManager) Command(npmArgs []string) []string { return []string{"sh", "-c", npmArgs[0]} }
+func (shellManager) Cache() error                      { return nil }
+
+func TestInstallToCI(t *testing.T) {
+	testCases := []struct {
+		commands [][]string
+		want     [][]string
+		wantOK   bool
+	}{
+		{[][]string{{"install"}, {"test"}}, [][]string{{"ci"}, {"test"}}, true},
+		{[][]string{{"i", "--no-audit", "--registry", "https://npm.example.com"}}, [][]string{{"ci", "--no-audit", "--registry", "https://npm.example.com"}}, true},
+		{[][]string{{"install", "lodash"}}, [][]string{{"install", "lodash"}}, false},
+		{[][]string{{"install", "-g", "cordova"}}, [][]string{{"install", "-g", "cordova"}}, false},
+		{[][]string{{"ci"}, {"run", "build"}}, [][]string{{"ci"}, {"run", "build"}}, false},
+	}
+
+	for _, tc := range testCases {
+		got, ok := installToCI(tc.commands)
+		if !reflect.DeepEqual(got, tc.want) || ok != tc.wantOK {
+			t.Errorf("installToCI(%v) = %v, %v, want %v, %v", tc.commands, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestRunCommands(t *testing.T) {
+	commands := [][]string{{"true"}, {"exit 3"}, {"true"}}
+
+	testCases := []struct {
+		failFast bool
+		want     []string
+		wantCode int
+	}{
+		{true, []string{"succeeded", "failed (exit code 3)", "skipped"}, 3},
+		{false, []string{"succeeded", "failed (exit code 3)", "succeeded"}, 3},
+	}
+
+	for _, tc := range testCases {
+		results := runCommands(shellManager{}, t.TempDir(), commands, tc.failFast)
+		var statuses []string
+		for _, r := range results {
+			statuses = append(statuses, r.status())
+		}
+		if !reflect.DeepEqual(statuses, tc.want) {
+			t.Errorf("runCommands(fail fast: %v) = %v, want %v", tc.failFast, statuses, tc.want)
+		}
+		if code := commandExitCode(results); code != tc.wantCode {
+			t.Errorf("commandExitCode() = %d, want %d", code, tc.wantCode)
+		}
+	}
+}
+
+func TestRunCommandRetriesTransientFailure(t *testing.T) {
+	original := networkRetry
+	defer func() { networkRetry = original }()
+	networkRetry = newRetryPolicy(1)
+	networkRetry.sleep = func(time.Duration) {}
+
+	// fails with a transient error on the first run only
+	script := `test -f marker || { touch marker; echo "npm ERR! code ECONNRESET"; exit 1; }`
+	if result := runCommand(shellManager{}, t.TempDir(), []string{script}); result.err != nil {
+		t.Errorf("runCommand() error = %v, want retried and succeeded", result.err)
+	}
+
+	script = `echo "npm ERR! code E404"; exit 1`
+	if result := runCommand(shellManager{}, t.TempDir(), []string{script}); exitCode(result.err) != 1 {
+		t.Errorf("runCommand() error = %v, want permanent failure", result.err)
+	}
+}
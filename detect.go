@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// packageManagerSignal is a file or package.json field of the project indicating the package manager it uses
+type packageManagerSignal struct {
+	Manager string
+	Source  string
+}
+
+func (s packageManagerSignal) String() string {
+	return fmt.Sprintf("%s (%s)", s.Source, s.Manager)
+}
+
+// packageManagerDetection is the result of inspecting the project for package manager signals
+type packageManagerDetection struct {
+	// Manager is the package manager of the signal with the highest precedence, npm if there is no signal
+	Manager string
+	// Signals are all the signals found, in the order of precedence
+	Signals []packageManagerSignal
+}
+
+// conflicts returns the signals indicating another package manager than the one used
+func (d packageManagerDetection) conflicts(manager string) []packageManagerSignal {
+	var conflicts []packageManagerSignal
+	for _, s := range d.Signals {
+		if s.Manager != manager {
+			conflicts = append(conflicts, s)
+		}
+	}
+	return conflicts
+}
+
+// packageManagerLockfiles are the lockfiles in the order of precedence
+var packageManagerLockfiles = []packageManagerSignal{
+	{"yarn", "yarn.lock"},
+	{"pnpm", "pnpm-lock.yaml"},
+	{"bun", "bun.lock"},
+	{"bun", "bun.lockb"},
+	{"npm", "npm-shrinkwrap.json"},
+	{"npm", "package-lock.json"},
+}
+
+// packageManagerConfigFiles are the config files read only by a single package manager, in the order of precedence
+var packageManagerConfigFiles = []packageManagerSignal{
+	{"yarn", ".yarnrc.yml"},
+	{"yarn", ".yarnrc"},
+	{"pnpm", "pnpm-workspace.yaml"},
+	{"bun", "bunfig.toml"},
+}
+
+// pnpmNpmrcKeys are .npmrc settings only pnpm reads, .npmrc itself is read by npm, pnpm and Yarn 1 as well
+var pnpmNpmrcKeys = []string{
+	"node-linker",
+	"shamefully-hoist",
+	"hoist-pattern",
+	"public-hoist-pattern",
+	"auto-install-peers",
+	"strict-peer-dependencies",
+	"store-dir",
+	"virtual-store-dir",
+}
+
+// detectPackageManager inspects the project in the working directory for package manager signals.
+// Precedence: the packageManager field of package.json, the lockfiles, then the package manager specific config files
+// (.yarnrc.yml, .yarnrc, pnpm-workspace.yaml, bunfig.toml and the pnpm settings of .npmrc).
+// If package.json can not be read or its packageManager field is invalid, the package manager is detected from the other
+// signals and the detection is returned together with the error.
+func detectPackageManager(workdir string) (packageManagerDetection, error) {
+	var signals []packageManagerSignal
+
+	fieldSignal, fieldErr := packageManagerFieldSignal(workdir)
+	if fieldSignal != nil {
+		signals = append(signals, *fieldSignal)
+	}
+
+	for _, files := range [][]packageManagerSignal{packageManagerLockfiles, packageManagerConfigFiles} {
+		for _, s := range files {
+			exists, err := pathutil.IsPathExists(filepath.Join(workdir, s.Source))
+			if err != nil {
+				return packageManagerDetection{}, fmt.Errorf("failed to check if %s exists: %s", s.Source, err)
+			}
+			if exists {
+				signals = append(signals, s)
+			}
+		}
+	}
+
+	npmrc, err := readProjectNpmrc(workdir)
+	if err != nil {
+		return packageManagerDetection{}, err
+	}
+	var pnpmKeys []string
+	for _, key := range pnpmNpmrcKeys {
+		if _, ok := npmrc[key]; ok {
+			pnpmKeys = append(pnpmKeys, key)
+		}
+	}
+	if len(pnpmKeys) > 0 {
+		signals = append(signals, packageManagerSignal{"pnpm", fmt.Sprintf(".npmrc %s", strings.Join(pnpmKeys, ", "))})
+	}
+
+	d := packageManagerDetection{Manager: "npm", Signals: signals}
+	if len(signals) > 0 {
+		d.Manager = signals[0].Manager
+	}
+	if !sliceutil.IsStringInSlice(d.Manager, supportedPackageManagers) {
+		return packageManagerDetection{}, fmt.Errorf("unsupported package manager %s set by %s", d.Manager, signals[0].Source)
+	}
+	return d, fieldErr
+}
+
+// packageManagerFieldSignal returns the signal of the packageManager field of package.json, nil if it is not set
+func packageManagerFieldSignal(workdir string) (*packageManagerSignal, error) {
+	pth := filepath.Join(workdir, "package.json")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if package.json exists: %s", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	m, err := readPackageJSON(pth)
+	if err != nil {
+		return nil, err
+	}
+	if m.PackageManager == "" {
+		return nil, nil
+	}
+	pm, err := parsePackageManager(m.PackageManager)
+	if err != nil {
+		return nil, fmt.Errorf("invalid packageManager field: %s", err)
+	}
+	return &packageManagerSignal{pm.Name, "packageManager field"}, nil
+}
+
+// supportedPackageManagers are the package managers the step can run the command with
+var supportedPackageManagers = []string{"npm", "yarn", "pnpm", "bun"}
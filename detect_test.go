@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectPackageManager(t *testing.T) {
+	testCases := []struct {
+		files   map[string]string
+		want    string
+		signals []string
+		hasE    bool
+	}{
+		{map[string]string{}, "npm", nil, false},
+		{map[string]string{"package.json": `{}`, "package-lock.json": `{}`}, "npm", []string{"package-lock.json"}, false},
+		{map[string]string{"package.json": `{}`, "yarn.lock": ``}, "yarn", []string{"yarn.lock"}, false},
+		{map[string]string{"package.json": `{"packageManager": "yarn@1.22.19"}`}, "yarn", []string{"packageManager field"}, false},
+		{map[string]string{"package.json": `{"packageManager": "npm@10.2.4"}`}, "npm", []string{"packageManager field"}, false},
+		{map[string]string{"package.json": `{}`, "pnpm-lock.yaml": ``}, "pnpm", []string{"pnpm-lock.yaml"}, false},
+		{map[string]string{"package.json": `{"packageManager": "pnpm@8.10.2"}`}, "pnpm", []string{"packageManager field"}, false},
+		{map[string]string{"package.json": `{}`, "bun.lockb": ``}, "bun", []string{"bun.lockb"}, false},
+		{map[string]string{"package.json": `{"packageManager": "bun@1.1.34"}`}, "bun", []string{"packageManager field"}, false},
+		{map[string]string{"package.json": `{"packageManager": "yarn"}`}, "npm", nil, true},
+		{map[string]string{"package.json": `{"packageManager": "yarn"}`, "pnpm-lock.yaml": ``}, "pnpm", []string{"pnpm-lock.yaml"}, true},
+		{map[string]string{"package.json": `{`, "yarn.lock": ``}, "yarn", []string{"yarn.lock"}, true},
+		{map[string]string{"package.json": `{"packageManager": "deno@1.0.0"}`}, "", nil, true},
+		{
+			map[string]string{"package.json": `{}`, "package-lock.json": `{}`, "yarn.lock": ``},
+			"yarn", []string{"yarn.lock", "package-lock.json"}, false,
+		},
+		{
+			map[string]string{"package.json": `{"packageManager": "pnpm@8.10.2"}`, "yarn.lock": ``, ".yarnrc.yml": ``},
+			"pnpm", []string{"packageManager field", "yarn.lock", ".yarnrc.yml"}, false,
+		},
+		{
+			map[string]string{"package.json": `{}`, ".npmrc": "shamefully-hoist=true\nregistry=https://registry.example.com/"},
+			"pnpm", []string{".npmrc shamefully-hoist"}, false,
+		},
+		{map[string]string{"package.json": `{}`, ".npmrc": "engine-strict=true"}, "npm", nil, false},
+	}
+
+	for _, tc := range testCases {
+		dir := writeProjectFiles(t, tc.files)
+
+		got, err := detectPackageManager(dir)
+		if tc.hasE != (err != nil) {
+			t.Errorf("detectPackageManager(%v) error = %v, want error: %v", tc.files, err, tc.hasE)
+		}
+		if got.Manager != tc.want {
+			t.Errorf("detectPackageManager(%v) = %s, want %s", tc.files, got.Manager, tc.want)
+		}
+		var signals []string
+		for _, s := range got.Signals {
+			signals = append(signals, s.Source)
+		}
+		if !reflect.DeepEqual(signals, tc.signals) {
+			t.Errorf("detectPackageManager(%v) signals = %v, want %v", tc.files, signals, tc.signals)
+		}
+	}
+}
+
+func TestPackageManagerDetectionConflicts(t *testing.T) {
+	d := packageManagerDetection{
+		Manager: "yarn",
+		Signals: []packageManagerSignal{{"yarn", "yarn.lock"}, {"npm", "package-lock.json"}, {"yarn", ".yarnrc"}},
+	}
+
+	testCases := []struct {
+		manager string
+		want    []packageManagerSignal
+	}{
+		{"yarn", []packageManagerSignal{{"npm", "package-lock.json"}}},
+		{"npm", []packageManagerSignal{{"yarn", "yarn.lock"}, {"yarn", ".yarnrc"}}},
+	}
+
+	for _, tc := range testCases {
+		if got := d.conflicts(tc.manager); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("conflicts(%s) = %v, want %v", tc.manager, got, tc.want)
+		}
+	}
+	if got := (packageManagerDetection{Manager: "npm"}).conflicts("npm"); got != nil {
+		t.Errorf("conflicts() = %v, want none", got)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// checkEngines checks the running Node.js and npm versions against the engines field of package.json,
+// like npm does with the engine-strict config
+func checkEngines(m packageJSON, nodeVersion, npmVersion string) error {
+	engines := []struct {
+		name     string
+		rng      string
+		version  string
+		toolName string
+	}{
+		{"engines.node", m.Engines.Node, nodeVersion, "Node.js"},
+		{"engines.npm", m.Engines.Npm, npmVersion, "npm"},
+	}
+
+	var errs []string
+	for _, e := range engines {
+		if e.rng == "" {
+			continue
+		}
+		if e.version == "" {
+			errs = append(errs, fmt.Sprintf("%s `%s` can not be checked: %s is not installed", e.name, e.rng, e.toolName))
+			continue
+		}
+
+		satisfied, err := versionSatisfies(e.version, e.rng)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s `%s` can not be checked: %s", e.name, e.rng, err))
+		} else if !satisfied {
+			errs = append(errs, fmt.Sprintf("%s `%s` is not satisfied by the running %s %s", e.name, e.rng, e.toolName, e.version))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkProjectEngines checks the running versions against the engines field of the package.json in the working directory
+func checkProjectEngines(workdir, nodeVersion, npmVersion string) error {
+	pth := filepath.Join(workdir, "package.json")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return fmt.Errorf("failed to validate package.json path: %s", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	m, err := readPackageJSON(pth)
+	if err != nil {
+		return err
+	}
+	return checkEngines(m, nodeVersion, npmVersion)
+}
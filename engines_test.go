@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckEngines(t *testing.T) {
+	testCases := []struct {
+		pkgJSON     string
+		nodeVersion string
+		npmVersion  string
+		hasE        bool
+	}{
+		{`{}`, "18.17.0", "9.8.1", false},
+		{`{"engines":{"node":">=18","npm":"^9"}}`, "18.17.0", "9.8.1", false},
+		{`{"engines":{"node":">=20"}}`, "18.17.0", "9.8.1", true},
+		{`{"engines":{"npm":"^10"}}`, "18.17.0", "9.8.1", true},
+		{`{"engines":{"npm":"^9"}}`, "18.17.0", "", true},
+		{`{"engines":{"npm":"a.b.c"}}`, "18.17.0", "9.8.1", true},
+	}
+
+	for _, tc := range testCases {
+		m, err := parsePackageJSON(tc.pkgJSON)
+		if err != nil {
+			t.Fatalf("parsePackageJSON(%s) returned error: %s", tc.pkgJSON, err)
+		}
+
+		err = checkEngines(m, tc.nodeVersion, tc.npmVersion)
+		if tc.hasE != (err != nil) {
+			t.Errorf("checkEngines(%s, %s, %s) error = %v, want error: %v", tc.pkgJSON, tc.nodeVersion, tc.npmVersion, err, tc.hasE)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProjectFiles writes the files, keyed by their path relative to the project, into a new temp dir and returns its path
+func writeProjectFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		pth := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(pth), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pth, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// npmInstaller installs Node.js and npm when npm is not available on the machine
+type npmInstaller interface {
+	Name() string
+	Available() bool
+	Install() error
+}
+
+// commandInstaller installs npm by running the commands of a system package manager
+type commandInstaller struct {
+	name     string
+	tool     string
+	sudo     bool
+	commands [][]string
+}
+
+func (i commandInstaller) Name() string {
+	return i.name
+}
+
+func (i commandInstaller) Available() bool {
+	_, err := exec.LookPath(i.tool)
+	return err == nil
+}
+
+func (i commandInstaller) Install() error {
+	for _, args := range i.commands {
+		if i.sudo && needsSudo() {
+			args = append([]string{"sudo"}, args...)
+		}
+		if err := runInstallCommand(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asdfInstaller installs the latest Node.js with the asdf nodejs plugin
+type asdfInstaller struct{}
+
+func (asdfInstaller) Name() string {
+	return "asdf"
+}
+
+func (asdfInstaller) Available() bool {
+	_, err := exec.LookPath("asdf")
+	return err == nil
+}
+
+// Install installs the latest Node.js and puts its install directory first on PATH,
+// the asdf global version (asdf global is removed since asdf 0.16) and shims are left untouched
+func (asdfInstaller) Install() error {
+	// fails if the plugin is already added
+	if err := runInstallCommand("asdf", "plugin", "add", "nodejs"); err != nil {
+		log.Printf("%s", err)
+	}
+
+	ver, err := command.New("asdf", "latest", "nodejs").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get the latest Node.js version of asdf: %s", ver)
+	}
+	if err := runInstallCommand("asdf", "install", "nodejs", ver); err != nil {
+		return err
+	}
+
+	out, err := command.New("asdf", "where", "nodejs", ver).RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to locate the Node.js installed by asdf: %s", out)
+	}
+	return prependPath(filepath.Join(out, "bin"))
+}
+
+// nvmInstaller installs the latest LTS Node.js with nvm
+type nvmInstaller struct{}
+
+func (nvmInstaller) Name() string {
+	return "nvm"
+}
+
+func nvmScriptPath() string {
+	dir := os.Getenv("NVM_DIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".nvm")
+	}
+	return filepath.Join(dir, "nvm.sh")
+}
+
+func (nvmInstaller) Available() bool {
+	exists, err := pathutil.IsPathExists(nvmScriptPath())
+	return err == nil && exists
+}
+
+func (nvmInstaller) Install() error {
+	// nvm is a shell function, it has to be sourced in the same shell
+	source := fmt.Sprintf(". %q", nvmScriptPath())
+	if err := runInstallCommand("bash", "-c", source+" && nvm install --lts"); err != nil {
+		return err
+	}
+
+	out, err := command.New("bash", "-c", source+" >/dev/null && nvm which --lts").RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to locate the Node.js installed by nvm: %s", out)
+	}
+	return prependPath(filepath.Dir(out))
+}
+
+// tarballInstaller installs the latest LTS Node.js from the official distribution tarball
+type tarballInstaller struct {
+	dist  nodeDistClient
+	tools toolCache
+}
+
+func (tarballInstaller) Name() string {
+	return "tarball"
+}
+
+func (tarballInstaller) Available() bool {
+	_, err := exec.LookPath("tar")
+	return err == nil
+}
+
+func (i tarballInstaller) Install() error {
+	ver, err := i.dist.resolveVersion("lts/*")
+	if err != nil {
+		return err
+	}
+
+	binDir, err := installNode(i.dist, i.tools, ver)
+	if err != nil {
+		return err
+	}
+	return prependPath(binDir)
+}
+
+func newNpmInstallers(dist nodeDistClient, tools toolCache) map[string]npmInstaller {
+	return map[string]npmInstaller{
+		"brew":    commandInstaller{name: "brew", tool: "brew", commands: [][]string{{"brew", "install", "node"}}},
+		"apt":     commandInstaller{name: "apt", tool: "apt-get", sudo: true, commands: [][]string{{"apt-get", "-y", "install", "npm"}}},
+		"dnf":     commandInstaller{name: "dnf", tool: "dnf", sudo: true, commands: [][]string{{"dnf", "-y", "install", "nodejs", "npm"}}},
+		"yum":     commandInstaller{name: "yum", tool: "yum", sudo: true, commands: [][]string{{"yum", "-y", "install", "nodejs", "npm"}}},
+		"apk":     commandInstaller{name: "apk", tool: "apk", sudo: true, commands: [][]string{{"apk", "add", "--no-cache", "nodejs", "npm"}}},
+		"asdf":    asdfInstaller{},
+		"nvm":     nvmInstaller{},
+		"tarball": tarballInstaller{dist: dist, tools: tools},
+	}
+}
+
+// selectNpmInstaller returns the installer for the method, in auto mode the first available one
+// from the platform defaults, the version managers and the official tarball.
+func selectNpmInstaller(method string, dist nodeDistClient, tools toolCache) (npmInstaller, error) {
+	installers := newNpmInstallers(dist, tools)
+
+	if method != "" && method != "auto" {
+		installer, ok := installers[method]
+		if !ok {
+			return nil, fmt.Errorf("unknown npm install method: %s", method)
+		}
+		if !installer.Available() {
+			return nil, fmt.Errorf("npm install method %s is not available on this machine", method)
+		}
+		return installer, nil
+	}
+
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = []string{"brew"}
+	case "linux":
+		content, err := fileutil.ReadStringFromFile("/etc/os-release")
+		if err != nil {
+			log.Warnf("Failed to read /etc/os-release: %s", err)
+		}
+		osRelease := parseOSRelease(content)
+		log.Printf("Linux distribution: %s", osRelease["ID"])
+		candidates = linuxInstallers(osRelease)
+	default:
+		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+	candidates = append(candidates, "asdf", "nvm", "tarball")
+
+	for _, name := range candidates {
+		if installers[name].Available() {
+			return installers[name], nil
+		}
+	}
+	return nil, fmt.Errorf("none of the npm install methods are available: %s", strings.Join(candidates, ", "))
+}
+
+// parseOSRelease parses the key-value pairs of /etc/os-release
+// https://www.freedesktop.org/software/systemd/man/os-release.html
+func parseOSRelease(content string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i == -1 {
+			continue
+		}
+		values[line[:i]] = strings.Trim(line[i+1:], `"'`)
+	}
+	return values
+}
+
+// linuxInstallers returns the package manager installers of the distribution, based on the ID and ID_LIKE values
+func linuxInstallers(osRelease map[string]string) []string {
+	ids := append([]string{osRelease["ID"]}, strings.Fields(osRelease["ID_LIKE"])...)
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return []string{"apt"}
+		case "fedora", "rhel", "centos":
+			return []string{"dnf", "yum"}
+		case "alpine":
+			return []string{"apk"}
+		}
+	}
+	return nil
+}
+
+// needsSudo reports whether system package managers have to be run with sudo
+func needsSudo() bool {
+	if os.Geteuid() == 0 {
+		return false
+	}
+	_, err := exec.LookPath("sudo")
+	return err == nil
+}
+
+func runInstallCommand(args ...string) error {
+	cmd := command.New(args[0], args[1:]...)
+	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return fmt.Errorf("%s command failed: %s", args[0], out)
+		}
+		return fmt.Errorf("error running %s command: %s", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOSRelease(t *testing.T) {
+	content := `# comment
+NAME="Ubuntu"
+ID=ubuntu
+ID_LIKE=debian
+VERSION_ID='22.04'
+INVALID
+`
+	want := map[string]string{"NAME": "Ubuntu", "ID": "ubuntu", "ID_LIKE": "debian", "VERSION_ID": "22.04"}
+
+	if got := parseOSRelease(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOSRelease() = %v, want %v", got, want)
+	}
+}
+
+func TestLinuxInstallers(t *testing.T) {
+	testCases := []struct {
+		osRelease map[string]string
+		want      []string
+	}{
+		{map[string]string{"ID": "ubuntu", "ID_LIKE": "debian"}, []string{"apt"}},
+		{map[string]string{"ID": "linuxmint", "ID_LIKE": "ubuntu debian"}, []string{"apt"}},
+		{map[string]string{"ID": "rocky", "ID_LIKE": "rhel centos fedora"}, []string{"dnf", "yum"}},
+		{map[string]string{"ID": "alpine"}, []string{"apk"}},
+		{map[string]string{"ID": "arch"}, nil},
+		{map[string]string{}, nil},
+	}
+
+	for _, tc := range testCases {
+		if got := linuxInstallers(tc.osRelease); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("linuxInstallers(%v) = %v, want %v", tc.osRelease, got, tc.want)
+		}
+	}
+}
+
+func TestSelectNpmInstallerUnknownMethod(t *testing.T) {
+	if _, err := selectNpmInstaller("pacman", newNodeDistClient(""), toolCache{root: t.TempDir()}); err == nil {
+		t.Errorf("selectNpmInstaller should fail for an unknown method")
+	}
+}
+
+func TestAsdfInstaller(t *testing.T) {
+	binDir, installDir := t.TempDir(), t.TempDir()
+	logPth := filepath.Join(t.TempDir(), "asdf.log")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %q
+case "$1" in
+latest) echo 22.1.0 ;;
+where) echo %q ;;
+esac
+`, logPth, installDir)
+	if err := ioutil.WriteFile(filepath.Join(binDir, "asdf"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	originalPath := os.Getenv("PATH")
+	defer func() {
+		if err := os.Setenv("PATH", originalPath); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := os.Setenv("PATH", binDir+string(os.PathListSeparator)+originalPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (asdfInstaller{}).Install(); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	calls, err := ioutil.ReadFile(logPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCalls := "plugin add nodejs\nlatest nodejs\ninstall nodejs 22.1.0\nwhere nodejs 22.1.0\n"
+	if string(calls) != wantCalls {
+		t.Errorf("asdf calls = %q, want %q", calls, wantCalls)
+	}
+	if want := filepath.Join(installDir, "bin") + string(os.PathListSeparator); !strings.HasPrefix(os.Getenv("PATH"), want) {
+		t.Errorf("PATH = %s, want prefix %s", os.Getenv("PATH"), want)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kballard/go-shellquote"
+)
+
+// intentArgs returns the npm command arguments of the package manager neutral intent,
+// they are translated to the command line of the package manager like the command input.
+//
+// - install: install the dependencies
+// - ci: install the dependencies reproducibly, from the lockfile only
+// - test: run the test script
+// - build: run the build script
+// - run:<script> [args]: run the script, the arguments are passed to the script after --
+func intentArgs(intent string) ([]string, error) {
+	intent = strings.TrimSpace(intent)
+	switch intent {
+	case "install", "ci", "test":
+		return []string{intent}, nil
+	case "build":
+		return []string{"run", "build"}, nil
+	}
+
+	if strings.HasPrefix(intent, "run:") {
+		args, err := shellquote.Split(strings.TrimPrefix(intent, "run:"))
+		if err != nil {
+			return nil, fmt.Errorf("intent `%s` is not a valid CLI command: %s", intent, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("missing script name in intent `%s`, expected run:<script>", intent)
+		}
+
+		script, scriptArgs := args[0], args[1:]
+		if len(scriptArgs) > 0 && scriptArgs[0] == "--" {
+			scriptArgs = scriptArgs[1:]
+		}
+		if len(scriptArgs) == 0 {
+			return []string{"run", script}, nil
+		}
+		return append([]string{"run", script, "--"}, scriptArgs...), nil
+	}
+
+	return nil, fmt.Errorf("unknown intent `%s`, expected one of install, ci, test, build, run:<script>", intent)
+}
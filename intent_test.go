@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntentArgs(t *testing.T) {
+	testCases := []struct {
+		intent string
+		want   []string
+		hasE   bool
+	}{
+		{"install", []string{"install"}, false},
+		{"ci", []string{"ci"}, false},
+		{"test", []string{"test"}, false},
+		{"build", []string{"run", "build"}, false},
+		{"run:lint", []string{"run", "lint"}, false},
+		{" run: type-check ", []string{"run", "type-check"}, false},
+		{"run:lint --fix", []string{"run", "lint", "--", "--fix"}, false},
+		{"run:lint -- --fix", []string{"run", "lint", "--", "--fix"}, false},
+		{`run:e2e --spec "login page"`, []string{"run", "e2e", "--", "--spec", "login page"}, false},
+		{`run:lint "--fix`, nil, true},
+		{"run:", nil, true},
+		{"deploy", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := intentArgs(tc.intent)
+		if tc.hasE != (err != nil) {
+			t.Errorf("intentArgs(%s) error = %v, want error: %v", tc.intent, err, tc.hasE)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("intentArgs(%s) = %v, want %v", tc.intent, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/pathutil"
+	semver "github.com/hashicorp/go-version"
+)
+
+// npmLockfileNames are the npm lockfiles in the order of precedence, npm-shrinkwrap.json wins if both exist
+var npmLockfileNames = []string{"npm-shrinkwrap.json", "package-lock.json"}
+
+// npmLockfile holds the lockfile fields used by the step
+type npmLockfile struct {
+	Name            string
+	LockfileVersion int `json:"lockfileVersion"`
+}
+
+// npmLockfilePath returns the path of the npm lockfile in the working directory, empty if there is no lockfile
+func npmLockfilePath(workdir string) (string, error) {
+	for _, name := range npmLockfileNames {
+		pth := filepath.Join(workdir, name)
+		exists, err := pathutil.IsPathExists(pth)
+		if err != nil {
+			return "", fmt.Errorf("failed to check if %s exists: %s", pth, err)
+		}
+		if exists {
+			return pth, nil
+		}
+	}
+	return "", nil
+}
+
+// readNpmLockfile reads the npm lockfile of the working directory, Name is empty if there is no lockfile
+func readNpmLockfile(workdir string) (npmLockfile, error) {
+	pth, err := npmLockfilePath(workdir)
+	if err != nil || pth == "" {
+		return npmLockfile{}, err
+	}
+
+	name := filepath.Base(pth)
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return npmLockfile{}, fmt.Errorf("%s file read error: %s", name, err)
+	}
+	var lockfile npmLockfile
+	if err := json.Unmarshal([]byte(content), &lockfile); err != nil {
+		return npmLockfile{}, fmt.Errorf("failed to parse %s: %s", name, err)
+	}
+	lockfile.Name = name
+	return lockfile, nil
+}
+
+// minNpmMajorForLockfile returns the first npm major version handling the lockfile version without rewriting it.
+// lockfileVersion 1 was introduced by npm 5, versions 2 and 3 by npm 7.
+// https://docs.npmjs.com/cli/configuring-npm/package-lock-json#lockfileversion
+func minNpmMajorForLockfile(lockfileVersion int) int {
+	if lockfileVersion <= 1 {
+		return 5
+	}
+	return 7
+}
+
+// checkLockfileCompatibility returns an error if the npm version is older than the lockfile requires
+func checkLockfileCompatibility(lockfile npmLockfile, npmVersion string) error {
+	v, err := semver.NewVersion(npmVersion)
+	if err != nil {
+		return fmt.Errorf("`%s` is not valid semver string: %s", npmVersion, err)
+	}
+
+	if minMajor := minNpmMajorForLockfile(lockfile.LockfileVersion); v.Segments()[0] < minMajor {
+		return fmt.Errorf("%s has lockfileVersion %d, which requires npm %d or newer, but npm %s is used",
+			lockfile.Name, lockfile.LockfileVersion, minMajor, npmVersion)
+	}
+	return nil
+}
+
+// npmLockfileDependencies holds the lockfile fields describing the dependencies of the root package
+type npmLockfileDependencies struct {
+	LockfileVersion int `json:"lockfileVersion"`
+	// Packages holds the dependency ranges of the root package at the "" key, since lockfileVersion 2
+	Packages map[string]struct {
+		Dependencies         map[string]string `json:"dependencies"`
+		DevDependencies      map[string]string `json:"devDependencies"`
+		OptionalDependencies map[string]string `json:"optionalDependencies"`
+	} `json:"packages"`
+	// Dependencies holds the installed versions in lockfileVersion 1, which does not record the ranges
+	Dependencies map[string]struct {
+		Version string `json:"version"`
+	} `json:"dependencies"`
+}
+
+// mergedDependencies returns the dependency ranges of all kinds by package name
+func mergedDependencies(kinds ...map[string]string) map[string]string {
+	deps := map[string]string{}
+	for _, kind := range kinds {
+		for name, rng := range kind {
+			deps[name] = rng
+		}
+	}
+	return deps
+}
+
+// checkLockfileInSync returns an error listing the differences if the dependencies recorded in the lockfile
+// do not match the ones of package.json, like npm ci does before installing
+func checkLockfileInSync(m packageJSON, lockfileName, lockfileContent string) error {
+	var lockfile npmLockfileDependencies
+	if err := json.Unmarshal([]byte(lockfileContent), &lockfile); err != nil {
+		return fmt.Errorf("failed to parse %s: %s", lockfileName, err)
+	}
+
+	deps := mergedDependencies(m.Dependencies, m.DevDependencies, m.OptionalDependencies)
+	var diffs []string
+	if lockfile.LockfileVersion <= 1 {
+		for _, name := range sortedKeys(deps) {
+			locked, ok := lockfile.Dependencies[name]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("`%s` is missing", name))
+				continue
+			}
+			// ranges of git, file and tarball dependencies can not be compared with the version
+			if satisfied, err := versionSatisfies(locked.Version, deps[name]); err == nil && !satisfied {
+				diffs = append(diffs, fmt.Sprintf("`%s` %s does not satisfy `%s`", name, locked.Version, deps[name]))
+			}
+		}
+	} else {
+		root, ok := lockfile.Packages[""]
+		if !ok {
+			return fmt.Errorf("%s has no root package", lockfileName)
+		}
+
+		lockedDeps := mergedDependencies(root.Dependencies, root.DevDependencies, root.OptionalDependencies)
+		for _, name := range sortedKeys(deps) {
+			locked, ok := lockedDeps[name]
+			switch {
+			case !ok:
+				diffs = append(diffs, fmt.Sprintf("`%s` is missing", name))
+			case locked != deps[name]:
+				diffs = append(diffs, fmt.Sprintf("`%s` is locked as `%s` instead of `%s`", name, locked, deps[name]))
+			}
+		}
+		for _, name := range sortedKeys(lockedDeps) {
+			if _, ok := deps[name]; !ok {
+				diffs = append(diffs, fmt.Sprintf("`%s` is not in package.json", name))
+			}
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("%s is out of sync with package.json: %s", lockfileName, strings.Join(diffs, ", "))
+	}
+	return nil
+}
+
+// checkNpmLockfileInSync checks the npm lockfile of the working directory against its package.json, returns the lockfile name
+func checkNpmLockfileInSync(workdir string) (string, error) {
+	pth, err := npmLockfilePath(workdir)
+	if err != nil {
+		return "", err
+	}
+	if pth == "" {
+		return "", fmt.Errorf("no %s found", strings.Join(npmLockfileNames, " or "))
+	}
+	name := filepath.Base(pth)
+
+	m, err := readPackageJSON(filepath.Join(workdir, "package.json"))
+	if err != nil {
+		return name, err
+	}
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return name, fmt.Errorf("%s file read error: %s", name, err)
+	}
+	return name, checkLockfileInSync(m, name, content)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
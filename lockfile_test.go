@@ -0,0 +1,108 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestReadNpmLockfile(t *testing.T) {
+	testCases := []struct {
+		files map[string]string
+		want  npmLockfile
+		hasE  bool
+	}{
+		{map[string]string{"package-lock.json": `{"lockfileVersion": 3}`}, npmLockfile{"package-lock.json", 3}, false},
+		{map[string]string{"package-lock.json": `{"lockfileVersion": 3}`, "npm-shrinkwrap.json": `{"lockfileVersion": 1}`}, npmLockfile{"npm-shrinkwrap.json", 1}, false},
+		{map[string]string{}, npmLockfile{}, false},
+		{map[string]string{"package-lock.json": `{`}, npmLockfile{}, true},
+	}
+
+	for _, tc := range testCases {
+		dir := writeProjectFiles(t, tc.files)
+
+		got, err := readNpmLockfile(dir)
+		if tc.hasE != (err != nil) {
+			t.Errorf("readNpmLockfile(%v) error = %v, want error: %v", tc.files, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("readNpmLockfile(%v) = %+v, want %+v", tc.files, got, tc.want)
+		}
+	}
+}
+
+func TestCheckLockfileCompatibility(t *testing.T) {
+	testCases := []struct {
+		lockfileVersion int
+		npmVersion      string
+		hasE            bool
+	}{
+		{1, "6.14.6", false},
+		{1, "9.8.1", false},
+		{2, "6.14.6", true},
+		{3, "6.14.6", true},
+		{3, "7.0.0", false},
+		{3, "10.2.4", false},
+		{3, "invalid", true},
+	}
+
+	for _, tc := range testCases {
+		lockfile := npmLockfile{Name: "package-lock.json", LockfileVersion: tc.lockfileVersion}
+		err := checkLockfileCompatibility(lockfile, tc.npmVersion)
+		if tc.hasE != (err != nil) {
+			t.Errorf("checkLockfileCompatibility(%d, %s) error = %v, want error: %v", tc.lockfileVersion, tc.npmVersion, err, tc.hasE)
+		}
+	}
+}
+
+func TestCheckLockfileInSync(t *testing.T) {
+	m := packageJSON{
+		Dependencies:    map[string]string{"react": "^18.2.0"},
+		DevDependencies: map[string]string{"jest": "~29.7.0"},
+	}
+
+	testCases := []struct {
+		name     string
+		lockfile string
+		want     string
+	}{
+		{
+			name:     "v3 in sync",
+			lockfile: `{"lockfileVersion":3,"packages":{"":{"dependencies":{"react":"^18.2.0"},"devDependencies":{"jest":"~29.7.0"}}}}`,
+		},
+		{
+			name:     "v3 changed range",
+			lockfile: `{"lockfileVersion":3,"packages":{"":{"dependencies":{"react":"^17.0.0"},"devDependencies":{"jest":"~29.7.0"}}}}`,
+			want:     "package-lock.json is out of sync with package.json: `react` is locked as `^17.0.0` instead of `^18.2.0`",
+		},
+		{
+			name:     "v2 missing and removed dependencies",
+			lockfile: `{"lockfileVersion":2,"packages":{"":{"dependencies":{"react":"^18.2.0","lodash":"^4.17.21"}}}}`,
+			want:     "package-lock.json is out of sync with package.json: `jest` is missing, `lodash` is not in package.json",
+		},
+		{
+			name:     "v2 without root package",
+			lockfile: `{"lockfileVersion":2,"packages":{}}`,
+			want:     "package-lock.json has no root package",
+		},
+		{
+			name:     "v1 in sync",
+			lockfile: `{"lockfileVersion":1,"dependencies":{"react":{"version":"18.2.0"},"jest":{"version":"29.7.0"}}}`,
+		},
+		{
+			name:     "v1 version out of range",
+			lockfile: `{"lockfileVersion":1,"dependencies":{"react":{"version":"17.0.2"},"jest":{"version":"29.7.0"}}}`,
+			want:     "package-lock.json is out of sync with package.json: `react` 17.0.2 does not satisfy `^18.2.0`",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ""
+			if err := checkLockfileInSync(m, "package-lock.json", tc.lockfile); err != nil {
+				got = err.Error()
+			}
+			if got != tc.want {
+				t.Errorf("checkLockfileInSync() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
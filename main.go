@@ -1,92 +1,125 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 
+	"github.com/bitrise-io/go-steputils/cache"
 	"github.com/bitrise-io/go-steputils/stepconf"
 	"github.com/bitrise-io/go-utils/command"
 	"github.com/bitrise-io/go-utils/errorutil"
-	"github.com/bitrise-io/go-utils/fileutil"
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
-	"github.com/bitrise-io/go-utils/sliceutil"
-	semver "github.com/hashicorp/go-version"
-	"github.com/kballard/go-shellquote"
 )
 
 // Config model
 type Config struct {
 	Workdir    string `env:"workdir"`
-	Command    string `env:"command,required"`
+	Command    string `env:"command"`
+	Intent     string `env:"intent"`
 	NpmVersion string `env:"npm_version"`
+	Registry   string `env:"npm_registry"`
 	UseCache   bool   `env:"cache_local_deps,opt[true,false]"`
+
+	FailureMode string `env:"failure_mode,opt[fail-fast,run-all]"`
+	Parallel    bool   `env:"parallel,opt[true,false]"`
+	MaxParallel int    `env:"max_parallel"`
+	GroupOutput bool   `env:"group_output,opt[true,false]"`
+
+	EnableCorepack bool `env:"enable_corepack,opt[true,false]"`
+
+	NodeVersion string `env:"node_version"`
+	NodeMirror  string `env:"node_mirror"`
+
+	NpmInstallMethod string `env:"npm_install_method,opt[auto,brew,apt,dnf,yum,apk,tarball,asdf,nvm]"`
+	NpmInstallMode   string `env:"npm_install_mode,opt[global,isolated]"`
+	ExportPath       bool   `env:"export_path,opt[true,false]"`
+	CacheTools       bool   `env:"cache_tools,opt[true,false]"`
+	StrictEngines    bool   `env:"strict_engines,opt[true,false]"`
+	LockfileCheck    string `env:"lockfile_check,opt[upgrade,fail,off]"`
+
+	RestoreNpmAfterRun bool `env:"restore_npm_after_run,opt[true,false]"`
+
+	PreferCI       bool `env:"prefer_ci,opt[true,false]"`
+	NetworkRetries int  `env:"network_retries,range[0..10]"`
+
+	PackageManager         string `env:"package_manager,opt[auto,npm,yarn,pnpm,bun]"`
+	PackageManagerConflict string `env:"package_manager_conflict,opt[warn,fail]"`
+	BunDownloadURL         string `env:"bun_download_url"`
 }
 
-func getNpmVersionFromPackageJSON(path string) (string, error) {
-	jsonStr, err := fileutil.ReadStringFromFile(path)
+func getNpmVersionFromPackageJSON(path string) (versionRequirement, error) {
+	m, err := readPackageJSON(path)
 	if err != nil {
-		return "", fmt.Errorf("package.json file read error: %s", err)
+		return versionRequirement{}, err
 	}
 
-	ver, err := extractNpmVersion(jsonStr)
+	return toolRequirementFromPackageJSON(m, "npm")
+}
+
+func extractNpmVersion(jsonStr string) (versionRequirement, error) {
+	m, err := parsePackageJSON(jsonStr)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse package.json: %s", err)
+		return versionRequirement{}, err
 	}
-	return ver, nil
+
+	return toolRequirementFromPackageJSON(m, "npm")
 }
 
-func extractNpmVersion(jsonStr string) (string, error) {
-	type pkgJSON struct {
-		Engines struct {
-			Npm string
-		}
+// resolveNpmVersion resolves a version range or dist-tag to an npm version published to the registry,
+// or to the highest cached npm version satisfying the range if the registry is not reachable.
+func resolveNpmVersion(registry registryClient, tools toolCache, spec string) (string, error) {
+	ver, err := resolveWithToolCache(tools, "npm", spec, func(spec string) (string, error) {
+		return registry.resolveVersion("npm", spec)
+	})
+	if err != nil {
+		return "", err
 	}
-
-	var m pkgJSON
-	if err := json.Unmarshal([]byte(jsonStr), &m); err != nil {
-		return "", fmt.Errorf("json unmarshal error: %s", err)
+	if ver != spec {
+		log.Printf("Resolved npm version `%s` to %s", spec, ver)
 	}
+	return ver, nil
+}
 
-	if m.Engines.Npm == "" {
-		return "", nil
-	}
+// setNpmVersion installs the npm version from the tarball kept in the tool cache, downloading it if needed.
+// In isolated mode npm is installed into the tool cache and put first on PATH, instead of replacing the global npm.
+func setNpmVersion(registry registryClient, tools toolCache, req versionRequirement, ver string, isolated bool) error {
+	return installRegistryTool(registry, tools, "npm", req, ver, isolated)
+}
 
-	v, err := semver.NewVersion(m.Engines.Npm)
+// restoreGlobalNpm reinstalls the npm version the machine had before the step, if it was changed
+func restoreGlobalNpm(registry registryClient, tools toolCache, ver string) error {
+	currentVer, err := systemDefined()
 	if err != nil {
-		return "", fmt.Errorf("`%s` is not valid semver string: %s", m.Engines.Npm, err)
+		return err
+	}
+	if currentVer == ver {
+		return nil
 	}
 
-	return v.String(), nil
+	fmt.Println()
+	log.Infof("Restoring npm version %s", ver)
+
+	return setNpmVersion(registry, tools, versionRequirement{Spec: ver, Source: "preinstalled npm"}, ver, false)
 }
 
-func createInstallNpmCommand() (*command.Model, error) {
-	var args []string
-	switch runtime.GOOS {
-	case "darwin":
-		args = []string{"brew", "install", "node"}
-	case "linux":
-		args = []string{"apt-get", "-y", "install", "npm"}
-	default:
-		return nil, fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+// enableCorepack installs the Corepack shims of the package managers,
+// so that their commands use the version pinned in the packageManager field
+func enableCorepack(names ...string) error {
+	if _, err := exec.LookPath("corepack"); err != nil {
+		return fmt.Errorf("corepack not found on PATH, it is shipped with Node.js 14.19.0 and 16.9.0 or newer")
 	}
 
-	return command.New(args[0], args[1:]...), nil
-}
-
-func setNpmVersion(ver string) error {
-	cmd := command.New("npm", "install", "-g", "--force", fmt.Sprintf("npm@%s", ver))
+	cmd := command.New("corepack", append([]string{"enable"}, names...)...)
 	log.Donef(fmt.Sprintf("$ %s", cmd.PrintableCommandArgs()))
 	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
 		if errorutil.IsExitStatusError(err) {
-			return fmt.Errorf("npm command failed: %s", out)
+			return fmt.Errorf("corepack command failed: %s", out)
 		}
-		return fmt.Errorf("error running npm command: %s", err)
+		return fmt.Errorf("error running corepack command: %s", err)
 	}
 
 	return nil
@@ -112,17 +145,49 @@ func systemDefined() (string, error) {
 	return "", nil
 }
 
+// exitHooks are run before the step exits, also on failure
+var exitHooks []func()
+
+func runExitHooks() {
+	hooks := exitHooks
+	exitHooks = nil
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// exit ends the step, it is replaced in tests
+var exit = os.Exit
+
 func failf(f string, args ...interface{}) {
 	log.Errorf(f, args...)
-	os.Exit(1)
+	runExitHooks()
+	exit(1)
+}
+
+// redactedConfig returns the config with the secrets masked in the inputs which may contain them, to be printed
+func redactedConfig(r redactor, config Config) Config {
+	for _, input := range []*string{&config.Command, &config.Registry, &config.NodeMirror, &config.BunDownloadURL} {
+		*input = r.redact(*input)
+	}
+	return config
 }
 
 func main() {
+	redactor := setupRedaction(os.Environ())
+
 	var config Config
 	if err := stepconf.Parse(&config); err != nil {
 		failf("Process config: %s", err)
 	}
-	stepconf.Print(config)
+	stepconf.Print(redactedConfig(redactor, config))
+	networkRetry = newRetryPolicy(config.NetworkRetries)
+
+	originalPath := os.Getenv("PATH")
+	tools, err := newToolCache()
+	if err != nil {
+		failf("Process config: %s", err)
+	}
 
 	workdir, err := pathutil.AbsPath(config.Workdir)
 	if err != nil {
@@ -137,22 +202,149 @@ func main() {
 		failf("Process config: specified working directory path `%s` does not exist", workdir)
 	}
 
-	npmArgs, err := shellquote.Split(config.Command)
+	var commands [][]string
+	switch {
+	case config.Command != "" && config.Intent != "":
+		failf("Process config: command and intent can not be set at the same time")
+	case config.Intent != "":
+		if commands, err = parseIntents(config.Intent); err != nil {
+			failf("Process config: %s", err)
+		}
+		for _, npmArgs := range commands {
+			log.Printf("Intent maps to the npm command `%s`", strings.Join(npmArgs, " "))
+		}
+	case config.Command != "":
+		if commands, err = parseCommands(config.Command); err != nil {
+			failf("Process config: provided npm command/arguments is not a valid CLI command: %s", err)
+		}
+	}
+	if len(commands) == 0 {
+		failf("Process config: either command or intent has to be set")
+	}
+
+	fmt.Println()
+	log.Infof("Detecting package manager")
+
+	pmName := config.PackageManager
+	autoDetect := pmName == "" || pmName == "auto"
+	detection, err := detectPackageManager(workdir)
 	if err != nil {
-		failf("Process config: provided npm command/arguments is not a valid CLI command: %s", err)
+		if !autoDetect || detection.Manager == "" {
+			failf("Process config: failed to detect package manager: %s", err)
+		}
+		log.Warnf("Failed to read package manager from package.json: %s, detecting it from the lockfiles and config files", err)
+	}
+	for _, signal := range detection.Signals {
+		log.Printf("Found %s", signal)
+	}
+	if autoDetect {
+		pmName = detection.Manager
+	}
+	log.Printf("Package manager: %s", pmName)
+
+	if conflicts := detection.conflicts(pmName); len(conflicts) > 0 {
+		var sources []string
+		for _, signal := range conflicts {
+			sources = append(sources, signal.String())
+		}
+		msg := fmt.Sprintf("The project has signals of other package managers than %s: %s. "+
+			"Remove the lockfiles and config files of the unused package managers, or set the packageManager field of package.json.",
+			pmName, strings.Join(sources, ", "))
+		if config.PackageManagerConflict == "fail" {
+			failf("Process config: %s", msg)
+		}
+		log.Warnf("%s", msg)
+	}
+
+	if err := checkCommandScripts(workdir, commands); err != nil {
+		// yarn run and bun run also run the binaries of the dependencies
+		if pmName == "yarn" || pmName == "bun" {
+			log.Warnf("%s, the command fails unless it runs a binary of a dependency", err)
+		} else {
+			failf("Process config: %s", err)
+		}
 	}
 
-	if strings.HasPrefix(config.Command, "install") {
+	if config.PreferCI && pmName == "npm" {
+		if ciCommands, ok := installToCI(commands); ok {
+			fmt.Println()
+			log.Infof("Checking if npm ci can be used")
+
+			if lockfileName, err := checkNpmLockfileInSync(workdir); err != nil {
+				log.Warnf("%s, keeping npm install", err)
+			} else {
+				log.Donef("%s is in sync with package.json, running npm ci instead of npm install", lockfileName)
+				commands = ciCommands
+			}
+		}
+	} else if pmName == "npm" && hasCommand(commands, "install") {
 		log.Donef("\n" +
 			"Info: From npm version >= v5.7.0, you can use the `npm ci` command insead of `npm install`. Using this command might speeds up your workflow.\n" +
 			"It does not work without `package-lock.json` so please commit it into the VCS repository. " +
 			"More info: https://github.com/npm/npm/releases/tag/v5.7.0")
 	}
 
+	npmrc, err := readProjectNpmrc(workdir)
+	if err != nil {
+		failf("Process config: %s", err)
+	}
+	strictEngines := config.StrictEngines
+	if npmrc["engine-strict"] == "true" {
+		log.Printf("engine-strict is enabled in .npmrc")
+		strictEngines = true
+	}
+
+	nodeReq := nodeRequirement{Spec: config.NodeVersion, Source: "node_version input"}
+	if nodeReq.Spec == "" {
+		fmt.Println()
+		log.Infof("Autodetecting Node.js version")
+
+		nodeReq, err = getNodeVersion(workdir)
+		if err != nil {
+			if strictEngines {
+				failf("Install dependencies: failed to get Node.js version: %s", err)
+			}
+			log.Warnf("error getting Node.js version: %s", err)
+		}
+		if nodeReq.Spec == "" {
+			log.Printf("No Node.js version requirement found, using the preinstalled Node.js")
+		}
+	}
+
+	if nodeReq.Spec != "" {
+		fmt.Println()
+		log.Infof("Ensuring Node.js version %s", nodeReq.Spec)
+		log.Printf("Node.js version `%s` required by %s", nodeReq.Spec, nodeReq.Source)
+
+		if _, err := ensureNodeVersion(newNodeDistClient(config.NodeMirror), tools, nodeReq); err != nil {
+			failf("Install dependencies: %s", err)
+		}
+	}
+
+	registryURL := config.Registry
+	if registryURL == "" {
+		registryURL = npmConfigRegistry(workdir)
+	}
+	npmrcConfig, err := readNpmrcConfig(workdir)
+	if err != nil {
+		failf("Process config: %s", err)
+	}
+	registry, err := newRegistryClient(registryURL, npmrcConfig)
+	if err != nil {
+		failf("Process config: %s", err)
+	}
 	toInstall := false
-	toSet := config.NpmVersion
+	npmReq := versionRequirement{Spec: config.NpmVersion, Source: "npm_version input"}
+	if isDistTag(npmReq.Spec) {
+		fmt.Println()
+		log.Infof("Resolving npm dist-tag %s", npmReq.Spec)
+
+		if npmReq.Spec, err = resolveNpmVersion(registry, tools, npmReq.Spec); err != nil {
+			failf("Process config: failed to resolve npm version `%s`: %s", config.NpmVersion, err)
+		}
+	}
 
-	if toSet == "" {
+	if npmReq.Spec == "" {
 		fmt.Println()
 		log.Infof("Autodetecting npm version")
 		log.Printf("Checking package.json for npm version")
@@ -164,74 +356,217 @@ func main() {
 		}
 
 		if exists {
-			toSet, err = getNpmVersionFromPackageJSON(path)
+			npmReq, err = getNpmVersionFromPackageJSON(path)
 			if err != nil {
+				if strictEngines {
+					failf("Install dependencies: failed to get npm version: %s", err)
+				}
 				log.Warnf("error getting version: %s", err)
 			}
 		} else {
 			log.Warnf("No package.json found at path: %s", path)
 		}
+
+		if npmReq.Spec == "" {
+			log.Warnf("Could not read version information from package.json")
+		} else {
+			log.Printf("npm version `%s` required by %s", npmReq.Spec, npmReq.Source)
+		}
 	}
 
-	if toSet == "" {
-		log.Warnf("Could not read version information from package.json")
-		log.Printf("Locating preinstalled npm")
+	toSet := npmReq.Spec
+	if config.EnableCorepack && pmName == "npm" {
+		fmt.Println()
+		log.Infof("Enabling Corepack")
 
-		systemVer, err := systemDefined()
-		if err != nil {
-			failf("Install dependencies: failed to check installed npm version: %s", err)
+		if err := enableCorepack("npm"); err != nil {
+			failf("Install dependencies: failed to enable Corepack: %s", err)
+		}
+		if npmReq.Source == "packageManager" {
+			log.Printf("npm version %s is provisioned by Corepack", npmReq.Spec)
+			toSet = ""
 		}
-		if systemVer == "" {
-			log.Warnf("npm not found on PATH")
+	}
+
+	fmt.Println()
+	log.Infof("Locating preinstalled npm")
+
+	systemVer, err := systemDefined()
+	if err != nil {
+		failf("Install dependencies: failed to check installed npm version: %s", err)
+	}
+	if systemVer == "" {
+		log.Warnf("npm not found on PATH")
+		toInstall = true
+		if toSet == "" {
 			toSet = "latest"
-			toInstall = true
 		}
+	} else {
 		log.Printf("Preinstalled npm version: %s", systemVer)
+
+		if config.RestoreNpmAfterRun && config.NpmInstallMode != "isolated" {
+			originalVer := systemVer
+			exitHooks = append(exitHooks, func() {
+				if err := restoreGlobalNpm(registry, tools, originalVer); err != nil {
+					log.Warnf("Failed to restore npm version %s: %s", originalVer, err)
+				}
+			})
+		}
+
+		if toSet != "" {
+			satisfied, err := versionSatisfies(systemVer, toSet)
+			if err != nil {
+				log.Warnf("Failed to compare preinstalled npm version with `%s`: %s", toSet, err)
+			} else if satisfied {
+				log.Donef("Preinstalled npm version %s satisfies `%s`, skipping npm install", systemVer, toSet)
+				toSet = ""
+			} else {
+				log.Printf("Preinstalled npm version %s does not satisfy `%s`", systemVer, toSet)
+			}
+		}
 	}
 
 	if toInstall {
 		fmt.Println()
-		log.Infof("Ensuring npm version %s", toSet)
+		log.Infof("Installing npm")
 
-		cmd, err := createInstallNpmCommand()
+		installer, err := selectNpmInstaller(config.NpmInstallMethod, newNodeDistClient(config.NodeMirror), tools)
 		if err != nil {
 			failf("Install dependencies: %s", err)
 		}
-		log.Donef("$ %s", cmd.PrintableCommandArgs())
-		if err := cmd.Run(); err != nil {
+		log.Printf("Install method: %s", installer.Name())
+		if err := installer.Install(); err != nil {
 			failf("Install dependencies: failed to install npm: %s", err)
 		}
 	}
 
+	if toSet != "" {
+		spec := toSet
+		toSet, err = resolveNpmVersion(registry, tools, spec)
+		if err != nil {
+			if config.NpmVersion != "" || strictEngines {
+				failf("Process config: failed to resolve npm version `%s`: %s", spec, err)
+			}
+			log.Warnf("error resolving version `%s`: %s", spec, err)
+		}
+	}
+
 	if toSet != "" {
 		fmt.Println()
 		log.Infof("Ensuring npm version %s", toSet)
 
-		if err := setNpmVersion(toSet); err != nil {
+		if err := setNpmVersion(registry, tools, npmReq, toSet, config.NpmInstallMode == "isolated"); err != nil {
 			failf("Install dependencies: failed to install npm version `%s`: %s", toSet, err)
 		}
 	}
 
-	fmt.Println()
-	log.Infof("Running user provided command")
+	// only npm reads its lockfile, a stray one in the project of another package manager must not change the npm version
+	var lockfile npmLockfile
+	if pmName == "npm" {
+		if lockfile, err = readNpmLockfile(workdir); err != nil {
+			log.Warnf("Failed to read npm lockfile: %s", err)
+		}
+	}
+	if lockfile.Name != "" && config.LockfileCheck != "off" {
+		fmt.Println()
+		log.Infof("Checking %s compatibility", lockfile.Name)
+
+		currentVer, err := systemDefined()
+		if err != nil {
+			failf("Install dependencies: failed to check installed npm version: %s", err)
+		}
+		if err := checkLockfileCompatibility(lockfile, currentVer); err != nil {
+			minMajor := minNpmMajorForLockfile(lockfile.LockfileVersion)
+			if config.LockfileCheck == "fail" || config.NpmVersion != "" {
+				failf("Install dependencies: %s. Set the npm version (npm_version input, packageManager or engines.npm) to %d or newer, "+
+					"or regenerate %s with npm %s.", err, minMajor, lockfile.Name, currentVer)
+			}
+
+			log.Warnf("%s, upgrading npm", err)
+			lockfileReq := versionRequirement{Spec: fmt.Sprintf("^%d.0.0", minMajor), Source: lockfile.Name}
+			ver, err := resolveNpmVersion(registry, tools, lockfileReq.Spec)
+			if err != nil {
+				failf("Install dependencies: failed to resolve npm version `%s`: %s", lockfileReq.Spec, err)
+			}
+			if err := setNpmVersion(registry, tools, lockfileReq, ver, config.NpmInstallMode == "isolated"); err != nil {
+				failf("Install dependencies: failed to install npm version `%s`: %s", ver, err)
+			}
+		} else {
+			log.Printf("npm %s supports lockfileVersion %d", currentVer, lockfile.LockfileVersion)
+		}
+	}
+
+	pm, err := newPackageManager(pmName, toolProvisioner{
+		workdir:  workdir,
+		registry: registry,
+		tools:    tools,
+		corepack: config.EnableCorepack,
+		isolated: config.NpmInstallMode == "isolated",
+	}, strictEngines, config.BunDownloadURL)
+	if err != nil {
+		failf("Install dependencies: %s", err)
+	}
+	if pm.Name() != "npm" {
+		fmt.Println()
+		log.Infof("Ensuring %s version", pm.Name())
+
+		if err := pm.Setup(); err != nil {
+			failf("Install dependencies: failed to set up %s: %s", pm.Name(), err)
+		}
+	}
+
+	if config.ExportPath && os.Getenv("PATH") != originalPath {
+		if err := cache.NewEnvmanVariableSetter().Set("PATH", os.Getenv("PATH")); err != nil {
+			log.Warnf("Failed to export PATH: %s", err)
+		} else {
+			log.Printf("Exported PATH for the subsequent steps")
+		}
+	}
 
-	cmd := command.NewWithStandardOuts("npm", npmArgs...)
-	log.Donef("$ %s", cmd.PrintableCommandArgs())
-	cmd.SetDir(workdir)
-	if err := cmd.Run(); err != nil {
-		failf("Run: provided npm command failed: %s", err)
+	if config.CacheTools {
+		if err := cacheTools(tools); err != nil {
+			log.Warnf("Failed to mark tool cache for caching: %s", err)
+		}
+	}
+
+	outputs := stepOutputs{Workdir: workdir}
+	if outputs.NodeVersion, err = systemNodeVersion(); err != nil {
+		log.Warnf("Failed to get Node.js version: %s", err)
+	}
+	if outputs.NpmVersion, err = systemDefined(); err != nil {
+		log.Warnf("Failed to get npm version: %s", err)
+	}
+
+	if err := checkProjectEngines(workdir, outputs.NodeVersion, outputs.NpmVersion); err != nil {
+		if strictEngines {
+			failf("Install dependencies: %s", err)
+		}
+		log.Warnf("%s", err)
+	}
+
+	results, duration := runUserCommands(pm, workdir, commands, config)
+	if len(results) > 1 {
+		printCommandSummary(results)
+	}
+	outputs.CommandExitCode, outputs.CommandDuration = commandExitCode(results), duration
+
+	if err := exportOutputs(cache.NewEnvmanVariableSetter(), outputs); err != nil {
+		log.Warnf("Failed to export outputs: %s", err)
+	}
+	if outputs.CommandExitCode != 0 {
+		failf("Run: provided %s command failed", pm.Name())
 	}
 
 	// Only cache if npm command is install, node_modules could be included in the repository
 	// Expecting command as the first argument of npm
-	// npm commands: https://github.com/npm/cli/blob/36682d4482cddee0acc55e8d75b3bee6e78fff37/lib/config/cmd-list.js
-	if config.UseCache &&
-		(len(npmArgs) != 0) && sliceutil.IsStringInSlice(npmArgs[0], []string{"install", "isntall", "i", "add", "ci"}) {
-		if err := cacheNpm(workdir); err != nil {
+	if config.UseCache && hasCommand(commands, installCommands...) {
+		if err := pm.Cache(); err != nil {
 			log.Warnf("Failed to mark files for caching: %s", err)
 		}
 	}
 
+	runExitHooks()
+
 	fmt.Println()
 	log.Successf("Step success")
 }
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -12,6 +16,15 @@ func TestExtractNpmVersion(t *testing.T) {
 		hasE    bool
 	}{
 		{`{"engines":{"npm":"3.0.1"}}`, "3.0.1", false},
+		{`{"engines":{"npm":"^5.0.0"}}`, "^5.0.0", false},
+		{`{"engines":{"npm":">=8 <10"}}`, ">=8 <10", false},
+		{`{"engines":{"npm":"8.x || 9.x"}}`, "8.x || 9.x", false},
+		{`{"engines":{"npm":"^8"},"packageManager":"npm@10.2.4"}`, "10.2.4", false},
+		{`{"engines":{"npm":"^8"},"packageManager":"yarn@1.22.19"}`, "^8", false},
+		{`{"packageManager":"npm@^10"}`, "", true},
+		{`{"engines":{"npm":"^8"},"volta":{"node":"18.17.0","npm":"9.8.1"}}`, "9.8.1", false},
+		{`{"engines":{"npm":"^8"},"volta":{"npm":"bundled"}}`, "^8", false},
+		{`{"packageManager":"npm@10.2.4","volta":{"npm":"9.8.1"}}`, "10.2.4", false},
 		{`"engines":{"npm":"3.0.1"}}`, "", true},
 		{`{"engines":{}}`, "", true},
 		{`{"engines":{"npm":"a.b.c"}}`, "", true},
@@ -19,8 +32,8 @@ func TestExtractNpmVersion(t *testing.T) {
 
 	for _, tc := range testCases {
 		got, gotE := extractNpmVersion(tc.pkgJSON)
-		if got != tc.want {
-			t.Errorf(`getNpmVersionFromPackageJson(%s) returned %s instead of %s`, tc.pkgJSON, got, tc.want)
+		if got.Spec != tc.want {
+			t.Errorf(`getNpmVersionFromPackageJson(%s) returned %s instead of %s`, tc.pkgJSON, got.Spec, tc.want)
 		}
 
 		if !tc.hasE && gotE != nil {
@@ -28,3 +41,91 @@ func TestExtractNpmVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestRunExitHooks(t *testing.T) {
+	calls := 0
+	exitHooks = []func(){func() { calls++ }, func() { calls++ }}
+
+	runExitHooks()
+	runExitHooks()
+
+	if calls != 2 {
+		t.Errorf("exit hooks called %d times, want 2", calls)
+	}
+	if len(exitHooks) != 0 {
+		t.Errorf("exit hooks should be cleared after running")
+	}
+}
+
+func TestFailfRunsExitHooks(t *testing.T) {
+	originalExit := exit
+	defer func() { exit = originalExit }()
+	exitCode := -1
+	exit = func(code int) { exitCode = code }
+
+	called := false
+	exitHooks = []func(){func() { called = true }}
+
+	failf("Install dependencies: %s", "failed")
+
+	if !called {
+		t.Errorf("failf() did not run the exit hooks")
+	}
+	if exitCode != 1 {
+		t.Errorf("failf() exited with %d, want 1", exitCode)
+	}
+}
+
+// fakeNpm puts an npm script on PATH which prints ver for --version and logs the other calls
+func fakeNpm(t *testing.T, ver string) (logPth string, restore func()) {
+	binDir := t.TempDir()
+	logPth = filepath.Join(t.TempDir(), "npm.log")
+	script := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = "--version" ]; then
+  echo %s
+  exit 0
+fi
+echo "$@" >> %q
+`, ver, logPth)
+	if err := ioutil.WriteFile(filepath.Join(binDir, "npm"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	restore = restorePath(t)
+	if err := os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH")); err != nil {
+		t.Fatal(err)
+	}
+	return logPth, restore
+}
+
+func TestRestoreGlobalNpm(t *testing.T) {
+	logPth, restore := fakeNpm(t, "10.2.4")
+	defer restore()
+	tools := toolCache{root: t.TempDir()}
+	tarball := cacheTarball(t, tools, "npm", "9.8.1")
+
+	if err := restoreGlobalNpm(registryClient{}, tools, "9.8.1"); err != nil {
+		t.Fatalf("restoreGlobalNpm() error = %v", err)
+	}
+
+	calls, err := ioutil.ReadFile(logPth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("install -g --force %s\n", tarball); string(calls) != want {
+		t.Errorf("npm calls = %q, want %q", calls, want)
+	}
+}
+
+func TestRestoreGlobalNpmUnchanged(t *testing.T) {
+	logPth, restore := fakeNpm(t, "9.8.1")
+	defer restore()
+
+	if err := restoreGlobalNpm(registryClient{}, toolCache{root: t.TempDir()}, "9.8.1"); err != nil {
+		t.Fatalf("restoreGlobalNpm() error = %v", err)
+	}
+
+	if _, err := os.Stat(logPth); !os.IsNotExist(err) {
+		t.Errorf("restoreGlobalNpm() reinstalled the unchanged npm version")
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	semver "github.com/hashicorp/go-version"
+)
+
+const defaultNodeMirror = "https://nodejs.org/dist/"
+
+// nodeRequirement is the Node.js version required by the project
+type nodeRequirement struct {
+	// Spec is an exact version, an npm semver range or an alias (lts/*, lts/<codename>, node)
+	Spec string
+	// Source is the file or field the requirement comes from
+	Source string
+}
+
+// getNodeVersion detects the Node.js version required by the project.
+// Precedence: the Volta pin of package.json, the version manager files, then engines.node of package.json.
+func getNodeVersion(workdir string) (nodeRequirement, error) {
+	var m packageJSON
+	pth := filepath.Join(workdir, "package.json")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return nodeRequirement{}, fmt.Errorf("failed to validate package.json path: %s", err)
+	}
+	if exists {
+		if m, err = readPackageJSON(pth); err != nil {
+			return nodeRequirement{}, err
+		}
+	}
+
+	if m.Volta.Node != "" {
+		return nodeRequirement{Spec: strings.TrimSpace(m.Volta.Node), Source: "volta.node"}, nil
+	}
+
+	req, err := getNodeVersionFromFiles(workdir)
+	if err != nil || req.Spec != "" {
+		return req, err
+	}
+
+	if m.Engines.Node == "" {
+		return nodeRequirement{}, nil
+	}
+	if _, err := parseNpmRange(m.Engines.Node); err != nil {
+		return nodeRequirement{}, fmt.Errorf("`%s` is not valid semver range: %s", m.Engines.Node, err)
+	}
+
+	return nodeRequirement{Spec: strings.TrimSpace(m.Engines.Node), Source: "engines.node"}, nil
+}
+
+// getNodeVersionFromFiles reads the Node.js version from the version manager files in the working directory,
+// in the order of .nvmrc, .node-version and .tool-versions
+func getNodeVersionFromFiles(workdir string) (nodeRequirement, error) {
+	parsers := []struct {
+		name  string
+		parse func(string) string
+	}{
+		{".nvmrc", parseNodeVersionFile},
+		{".node-version", parseNodeVersionFile},
+		{".tool-versions", parseToolVersions},
+	}
+
+	for _, p := range parsers {
+		pth := filepath.Join(workdir, p.name)
+		exists, err := pathutil.IsPathExists(pth)
+		if err != nil {
+			return nodeRequirement{}, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+		}
+		if !exists {
+			continue
+		}
+
+		content, err := fileutil.ReadStringFromFile(pth)
+		if err != nil {
+			return nodeRequirement{}, fmt.Errorf("%s file read error: %s", p.name, err)
+		}
+		if spec := p.parse(content); spec != "" {
+			return nodeRequirement{Spec: spec, Source: p.name}, nil
+		}
+	}
+
+	return nodeRequirement{}, nil
+}
+
+// parseNodeVersionFile returns the version from .nvmrc or .node-version, the first non-comment line
+func parseNodeVersionFile(content string) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i != -1 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// parseToolVersions returns the nodejs version from an asdf .tool-versions file
+func parseToolVersions(content string) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && (fields[0] == "nodejs" || fields[0] == "node") {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// nodeRelease is an entry of the Node.js distribution index
+type nodeRelease struct {
+	Version string      `json:"version"`
+	LTS     interface{} `json:"lts"`
+}
+
+func (r nodeRelease) ltsName() string {
+	if name, ok := r.LTS.(string); ok {
+		return name
+	}
+	return ""
+}
+
+type nodeDistClient struct {
+	mirror string
+	client *http.Client
+}
+
+func newNodeDistClient(mirror string) nodeDistClient {
+	if mirror == "" {
+		mirror = defaultNodeMirror
+	}
+	return nodeDistClient{
+		mirror: strings.TrimSuffix(mirror, "/") + "/",
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (c nodeDistClient) get(u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fetch(c.client, req)
+}
+
+func (c nodeDistClient) releases() ([]nodeRelease, error) {
+	body, err := c.get(c.mirror + "index.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []nodeRelease
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse Node.js release index: %s", err)
+	}
+	return releases, nil
+}
+
+// resolveVersion returns the highest released Node.js version matching the version, range or alias.
+func (c nodeDistClient) resolveVersion(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	alias := strings.ToLower(spec)
+
+	var match func(nodeRelease, *semver.Version) bool
+	switch {
+	case alias == "node" || alias == "latest" || alias == "current" || alias == "stable":
+		match = func(nodeRelease, *semver.Version) bool { return true }
+	case alias == "lts" || alias == "lts/*":
+		match = func(r nodeRelease, _ *semver.Version) bool { return r.ltsName() != "" }
+	case strings.HasPrefix(alias, "lts/"):
+		codename := strings.TrimPrefix(alias, "lts/")
+		match = func(r nodeRelease, _ *semver.Version) bool { return strings.ToLower(r.ltsName()) == codename }
+	default:
+		rng, err := parseNpmRange(spec)
+		if err != nil {
+			return "", err
+		}
+		if v, ok := rng.exactVersion(); ok {
+			return v.String(), nil
+		}
+		match = func(_ nodeRelease, v *semver.Version) bool { return rng.Check(v) }
+	}
+
+	releases, err := c.releases()
+	if err != nil {
+		return "", err
+	}
+
+	var best *semver.Version
+	for _, r := range releases {
+		v, err := semver.NewVersion(r.Version)
+		if err != nil {
+			continue
+		}
+		if match(r, v) && (best == nil || v.GreaterThan(best)) {
+			best = v
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no released Node.js version satisfies `%s`", spec)
+	}
+	return best.String(), nil
+}
+
+// nodeDistName returns the distribution name for the current platform, like node-v18.17.0-linux-x64
+func nodeDistName(version string) (string, error) {
+	var platform string
+	switch runtime.GOOS {
+	case "darwin", "linux":
+		platform = runtime.GOOS
+	default:
+		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	}
+
+	var arch string
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = "x64"
+	case "arm64":
+		arch = "arm64"
+	default:
+		return "", fmt.Errorf("unsupported architecture: %s", runtime.GOARCH)
+	}
+
+	return fmt.Sprintf("node-v%s-%s-%s", version, platform, arch), nil
+}
+
+// parseShasums returns the checksum of the file from a SHASUMS256.txt content
+func parseShasums(content, filename string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum found for %s", filename)
+}
+
+// download downloads and verifies the distribution tarball of the Node.js version into dir
+func (c nodeDistClient) download(version, dir string) (string, error) {
+	name, err := nodeDistName(version)
+	if err != nil {
+		return "", err
+	}
+	filename := name + ".tar.gz"
+	base := fmt.Sprintf("%sv%s/", c.mirror, version)
+
+	shasums, err := c.get(base + "SHASUMS256.txt")
+	if err != nil {
+		return "", err
+	}
+	checksum, err := parseShasums(string(shasums), filename)
+	if err != nil {
+		return "", err
+	}
+
+	pth := filepath.Join(dir, filename)
+	if err := downloadFile(c.client, base+filename, pth); err != nil {
+		return "", err
+	}
+	if err := verifyFileHash(pth, "sha256", checksum); err != nil {
+		return "", err
+	}
+	log.Printf("Verified SHA-256 checksum of %s", filename)
+
+	return pth, nil
+}
+
+// installNode installs the Node.js distribution into the tool cache, unless it is already there,
+// and returns its bin directory
+func installNode(dist nodeDistClient, tools toolCache, version string) (string, error) {
+	name, err := nodeDistName(version)
+	if err != nil {
+		return "", err
+	}
+	binDir := filepath.Join(tools.path("node", version), name, "bin")
+
+	if tools.isComplete("node", version) {
+		log.Printf("Using cached Node.js %s", version)
+		return binDir, nil
+	}
+
+	dir, err := tools.prepare("node", version)
+	if err != nil {
+		return "", err
+	}
+	tarball, err := dist.download(version, dir)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := command.New("tar", "-xzf", tarball, "-C", dir)
+	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	if out, err := cmd.RunAndReturnTrimmedCombinedOutput(); err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return "", fmt.Errorf("tar command failed: %s", out)
+		}
+		return "", fmt.Errorf("error running tar command: %s", err)
+	}
+
+	if err := os.Remove(tarball); err != nil {
+		log.Warnf("Failed to remove %s: %s", tarball, err)
+	}
+	if err := tools.markComplete("node", version); err != nil {
+		return "", err
+	}
+	return binDir, nil
+}
+
+// ensureNodeVersion makes sure a Node.js version satisfying the requirement is the first on PATH
+// and returns the version in use
+func ensureNodeVersion(dist nodeDistClient, tools toolCache, req nodeRequirement) (string, error) {
+	systemVer, err := systemNodeVersion()
+	if err != nil {
+		return "", fmt.Errorf("failed to check installed Node.js version: %s", err)
+	}
+	if systemVer != "" {
+		log.Printf("Preinstalled Node.js version: %s", systemVer)
+		if satisfied, err := versionSatisfies(systemVer, req.Spec); err == nil && satisfied {
+			log.Donef("Preinstalled Node.js version %s satisfies `%s`, skipping Node.js install", systemVer, req.Spec)
+			return systemVer, nil
+		}
+	}
+
+	ver, err := resolveWithToolCache(tools, "node", req.Spec, dist.resolveVersion)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve Node.js version `%s`: %s", req.Spec, err)
+	}
+	if ver != req.Spec {
+		log.Printf("Resolved Node.js version `%s` to %s", req.Spec, ver)
+	}
+	if ver == systemVer {
+		log.Donef("Preinstalled Node.js version %s matches, skipping Node.js install", systemVer)
+		return systemVer, nil
+	}
+
+	binDir, err := installNode(dist, tools, ver)
+	if err != nil {
+		return "", fmt.Errorf("failed to install Node.js %s: %s", ver, err)
+	}
+	if err := prependPath(binDir); err != nil {
+		return "", fmt.Errorf("failed to add %s to PATH: %s", binDir, err)
+	}
+	log.Printf("Added %s to PATH", binDir)
+
+	return ver, nil
+}
+
+// prependPath puts the directory first on PATH for the commands run by the step
+func prependPath(dir string) error {
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func systemNodeVersion() (string, error) {
+	if _, err := exec.LookPath("node"); err != nil {
+		return "", nil
+	}
+
+	cmd := command.New("node", "--version")
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return "", fmt.Errorf("node command failed: %s", out)
+		}
+		return "", fmt.Errorf("error running node command: %s", err)
+	}
+
+	return strings.TrimPrefix(out, "v"), nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseNodeVersionFiles(t *testing.T) {
+	testCases := []struct {
+		parse   func(string) string
+		content string
+		want    string
+	}{
+		{parseNodeVersionFile, "v18.17.0\n", "v18.17.0"},
+		{parseNodeVersionFile, "# comment\n\nlts/hydrogen # pinned\n", "lts/hydrogen"},
+		{parseNodeVersionFile, "", ""},
+		{parseToolVersions, "ruby 3.2.2\nnodejs 20.5.1\n", "20.5.1"},
+		{parseToolVersions, "# nodejs 16\nnode 18 19\n", "18"},
+		{parseToolVersions, "python 3.11\n", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.parse(tc.content); got != tc.want {
+			t.Errorf("parse(%q) = %s, want %s", tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestGetNodeVersion(t *testing.T) {
+	testCases := []struct {
+		files map[string]string
+		want  nodeRequirement
+	}{
+		{map[string]string{".nvmrc": "18", "package.json": `{"volta":{"node":"20.5.1"}}`}, nodeRequirement{"20.5.1", "volta.node"}},
+		{map[string]string{".nvmrc": "18", ".node-version": "16", "package.json": `{"engines":{"node":">=14"}}`}, nodeRequirement{"18", ".nvmrc"}},
+		{map[string]string{".tool-versions": "nodejs 20.5.1", "package.json": `{"engines":{"node":">=14"}}`}, nodeRequirement{"20.5.1", ".tool-versions"}},
+		{map[string]string{"package.json": `{"engines":{"node":">=14"}}`}, nodeRequirement{">=14", "engines.node"}},
+		{map[string]string{}, nodeRequirement{}},
+	}
+
+	for _, tc := range testCases {
+		dir := writeProjectFiles(t, tc.files)
+
+		got, err := getNodeVersion(dir)
+		if err != nil {
+			t.Errorf("getNodeVersion(%v) returned error: %s", tc.files, err)
+		}
+		if got != tc.want {
+			t.Errorf("getNodeVersion(%v) = %+v, want %+v", tc.files, got, tc.want)
+		}
+	}
+}
+
+func TestNodeDistClientResolveVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/index.json" {
+			http.NotFound(w, r)
+			return
+		}
+		if _, err := fmt.Fprint(w, `[
+			{"version": "v21.1.0", "lts": false},
+			{"version": "v20.9.0", "lts": "Iron"},
+			{"version": "v18.18.2", "lts": "Hydrogen"},
+			{"version": "v18.17.0", "lts": "Hydrogen"},
+			{"version": "v16.20.2", "lts": "Gallium"}
+		]`); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	client := newNodeDistClient(server.URL)
+	testCases := []struct {
+		spec string
+		want string
+		hasE bool
+	}{
+		{"node", "21.1.0", false},
+		{"lts/*", "20.9.0", false},
+		{"lts/hydrogen", "18.18.2", false},
+		{"v18", "18.18.2", false},
+		{">=16 <18", "16.20.2", false},
+		{"18.17.0", "18.17.0", false},
+		{"^22", "", true},
+		{"lts/unknown", "", true},
+	}
+
+	for _, tc := range testCases {
+		got, err := client.resolveVersion(tc.spec)
+		if tc.hasE != (err != nil) {
+			t.Errorf("resolveVersion(%s) error = %v, want error: %v", tc.spec, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("resolveVersion(%s) = %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestParseShasums(t *testing.T) {
+	content := "aaa  node-v18.17.0-darwin-arm64.tar.gz\nbbb  node-v18.17.0-linux-x64.tar.gz\nccc  node-v18.17.0-linux-x64.tar.xz\n"
+
+	got, err := parseShasums(content, "node-v18.17.0-linux-x64.tar.gz")
+	if err != nil || got != "bbb" {
+		t.Errorf("parseShasums() = %s, %v, want bbb", got, err)
+	}
+
+	if _, err := parseShasums(content, "node-v18.17.0-win-x64.zip"); err == nil {
+		t.Errorf("parseShasums() should fail for a missing file")
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+// npmRange is a parsed npm semver range: a union (||) of comparator sets,
+// where every comparator in a set has to match.
+// Syntax reference: https://github.com/npm/node-semver#ranges
+type npmRange [][]comparator
+
+type comparator struct {
+	op      string
+	version *semver.Version
+}
+
+var (
+	operatorSpaceRegexp = regexp.MustCompile(`(<=|>=|<|>|=|~>|~|\^)\s+`)
+	hyphenRangeRegexp   = regexp.MustCompile(`^(\S+)\s+-\s+(\S+)$`)
+	comparatorRegexp    = regexp.MustCompile(`^(<=|>=|<|>|=|~>|~|\^)?(.*)$`)
+)
+
+// partialVersion is a version where trailing parts might be missing or wildcards (x, X, *).
+type partialVersion struct {
+	parts []int
+	pre   string
+}
+
+func parsePartialVersion(s string) (partialVersion, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return partialVersion{}, nil
+	}
+
+	var p partialVersion
+	if i := strings.Index(s, "+"); i != -1 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i != -1 {
+		s, p.pre = s[:i], s[i+1:]
+		if p.pre == "" {
+			return partialVersion{}, fmt.Errorf("empty prerelease in version")
+		}
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return partialVersion{}, fmt.Errorf("too many version parts")
+	}
+	for _, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			break
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return partialVersion{}, fmt.Errorf("invalid version part `%s`", f)
+		}
+		p.parts = append(p.parts, n)
+	}
+	if p.pre != "" && len(p.parts) != 3 {
+		return partialVersion{}, fmt.Errorf("prerelease requires a full version")
+	}
+
+	return p, nil
+}
+
+func (p partialVersion) full() bool {
+	return len(p.parts) == 3
+}
+
+// version returns the lowest version matching the partial.
+func (p partialVersion) version() *semver.Version {
+	parts := append(append([]int{}, p.parts...), 0, 0, 0)[:3]
+	s := fmt.Sprintf("%d.%d.%d", parts[0], parts[1], parts[2])
+	if p.pre != "" {
+		s += "-" + p.pre
+	}
+	return semver.Must(semver.NewVersion(s))
+}
+
+// next returns the lowest version above every version matching the partial.
+func (p partialVersion) next() *semver.Version {
+	parts := append(append([]int{}, p.parts...), 0, 0, 0)[:3]
+	switch len(p.parts) {
+	case 1:
+		parts = []int{parts[0] + 1, 0, 0}
+	case 2:
+		parts = []int{parts[0], parts[1] + 1, 0}
+	case 3:
+		parts = []int{parts[0], parts[1], parts[2] + 1}
+	}
+	return semver.Must(semver.NewVersion(fmt.Sprintf("%d.%d.%d-0", parts[0], parts[1], parts[2])))
+}
+
+// parseNpmRange parses an npm semver range, like `^5.0.0`, `>=8 <10` or `8.x || 9.x`.
+func parseNpmRange(s string) (npmRange, error) {
+	var r npmRange
+	for _, part := range strings.Split(s, "||") {
+		set, err := parseComparatorSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range `%s`: %s", strings.TrimSpace(s), err)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+func parseComparatorSet(s string) ([]comparator, error) {
+	if m := hyphenRangeRegexp.FindStringSubmatch(s); m != nil {
+		from, err := parsePartialVersion(m[1])
+		if err != nil {
+			return nil, err
+		}
+		to, err := parsePartialVersion(m[2])
+		if err != nil {
+			return nil, err
+		}
+
+		set := []comparator{{">=", from.version()}}
+		if len(to.parts) == 0 {
+			return set, nil
+		}
+		if to.full() {
+			return append(set, comparator{"<=", to.version()}), nil
+		}
+		return append(set, comparator{"<", to.next()}), nil
+	}
+
+	s = operatorSpaceRegexp.ReplaceAllString(s, "$1")
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return []comparator{{">=", semver.Must(semver.NewVersion("0.0.0"))}}, nil
+	}
+
+	var set []comparator
+	for _, f := range fields {
+		c, err := parseComparator(f)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, c...)
+	}
+	return set, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	m := comparatorRegexp.FindStringSubmatch(s)
+	op := m[1]
+	p, err := parsePartialVersion(m[2])
+	if err != nil {
+		return nil, err
+	}
+
+	anyVersion := []comparator{{">=", semver.Must(semver.NewVersion("0.0.0"))}}
+	noVersion := []comparator{{"<", semver.Must(semver.NewVersion("0.0.0-0"))}}
+
+	switch op {
+	case "", "=":
+		if len(p.parts) == 0 {
+			return anyVersion, nil
+		}
+		if p.full() {
+			return []comparator{{"=", p.version()}}, nil
+		}
+		return []comparator{{">=", p.version()}, {"<", p.next()}}, nil
+	case "~", "~>":
+		if len(p.parts) == 0 {
+			return anyVersion, nil
+		}
+		upper := p
+		if len(p.parts) > 2 {
+			upper = partialVersion{parts: p.parts[:2]}
+		}
+		return []comparator{{">=", p.version()}, {"<", upper.next()}}, nil
+	case "^":
+		if len(p.parts) == 0 {
+			return anyVersion, nil
+		}
+		// the upper bound bumps the left-most non-zero part
+		upper := partialVersion{parts: p.parts[:1]}
+		switch {
+		case p.parts[0] != 0 || len(p.parts) == 1:
+		case p.parts[1] != 0 || len(p.parts) == 2:
+			upper.parts = p.parts[:2]
+		default:
+			upper.parts = p.parts
+		}
+		return []comparator{{">=", p.version()}, {"<", upper.next()}}, nil
+	case ">":
+		if len(p.parts) == 0 {
+			return noVersion, nil
+		}
+		if p.full() {
+			return []comparator{{">", p.version()}}, nil
+		}
+		// >1.2 is >=1.3.0, the prereleases of 1.3.0 do not match
+		return []comparator{{">=", p.next().Core()}}, nil
+	case ">=":
+		return []comparator{{">=", p.version()}}, nil
+	case "<":
+		if len(p.parts) == 0 {
+			return noVersion, nil
+		}
+		if p.full() {
+			return []comparator{{"<", p.version()}}, nil
+		}
+		return []comparator{{"<", semver.Must(semver.NewVersion(p.version().String() + "-0"))}}, nil
+	case "<=":
+		if len(p.parts) == 0 {
+			return anyVersion, nil
+		}
+		if p.full() {
+			return []comparator{{"<=", p.version()}}, nil
+		}
+		return []comparator{{"<", p.next()}}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator `%s`", op)
+}
+
+func (c comparator) check(v *semver.Version) bool {
+	switch c.op {
+	case "=":
+		return v.Equal(c.version)
+	case ">":
+		return v.GreaterThan(c.version)
+	case ">=":
+		return v.GreaterThanOrEqual(c.version)
+	case "<":
+		return v.LessThan(c.version)
+	case "<=":
+		return v.LessThanOrEqual(c.version)
+	}
+	return false
+}
+
+// Check reports whether the version satisfies the range.
+// Prerelease versions only match if a comparator of the same set refers to a prerelease of the same major.minor.patch,
+// as npm does.
+func (r npmRange) Check(v *semver.Version) bool {
+	for _, set := range r {
+		if checkComparatorSet(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkComparatorSet(set []comparator, v *semver.Version) bool {
+	for _, c := range set {
+		if !c.check(v) {
+			return false
+		}
+	}
+
+	if v.Prerelease() == "" {
+		return true
+	}
+	for _, c := range set {
+		if c.version.Prerelease() != "" && c.version.Core().Equal(v.Core()) {
+			return true
+		}
+	}
+	return false
+}
+
+// exactVersion returns the pinned version if the range matches a single version only.
+func (r npmRange) exactVersion() (*semver.Version, bool) {
+	if len(r) == 1 && len(r[0]) == 1 && r[0][0].op == "=" {
+		return r[0][0].version, true
+	}
+	return nil, false
+}
+
+// minVersion returns the lowest version satisfying the range, like node-semver's minVersion.
+func (r npmRange) minVersion() (*semver.Version, bool) {
+	zero := semver.Must(semver.NewVersion("0.0.0"))
+	if r.Check(zero) {
+		return zero, true
+	}
+
+	var min *semver.Version
+	for _, set := range r {
+		var lower *semver.Version
+		for _, c := range set {
+			candidate := c.version
+			switch c.op {
+			case "=", ">=":
+			case ">":
+				// the lowest version greater than c.version
+				if pre := c.version.Prerelease(); pre != "" {
+					candidate = semver.Must(semver.NewVersion(fmt.Sprintf("%s-%s.0", c.version.Core(), pre)))
+				} else {
+					s := c.version.Segments()
+					candidate = semver.Must(semver.NewVersion(fmt.Sprintf("%d.%d.%d", s[0], s[1], s[2]+1)))
+				}
+			default:
+				continue
+			}
+			if lower == nil || candidate.GreaterThan(lower) {
+				lower = candidate
+			}
+		}
+		if lower != nil && checkComparatorSet(set, lower) && (min == nil || lower.LessThan(min)) {
+			min = lower
+		}
+	}
+	return min, min != nil
+}
+
+// maxSatisfying returns the highest version from the list satisfying the range, invalid versions are skipped.
+func (r npmRange) maxSatisfying(versions []string) (*semver.Version, bool) {
+	var best *semver.Version
+	for _, s := range versions {
+		v, err := semver.NewVersion(s)
+		if err != nil {
+			continue
+		}
+		if r.Check(v) && (best == nil || v.GreaterThan(best)) {
+			best = v
+		}
+	}
+	return best, best != nil
+}
+
+// versionSatisfies reports whether the version satisfies the given npm version range.
+func versionSatisfies(version, spec string) (bool, error) {
+	v, err := semver.NewVersion(strings.TrimSpace(version))
+	if err != nil {
+		return false, fmt.Errorf("`%s` is not valid semver string: %s", version, err)
+	}
+	r, err := parseNpmRange(spec)
+	if err != nil {
+		return false, err
+	}
+	return r.Check(v), nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"testing"
+
+	semver "github.com/hashicorp/go-version"
+)
+
+func TestNpmRangeCheck(t *testing.T) {
+	testCases := []struct {
+		rng     string
+		version string
+		want    bool
+	}{
+		{"^5.0.0", "5.10.2", true},
+		{"^5.0.0", "6.0.0", false},
+		{"^5.0.0", "5.1.0-beta.1", false},
+		{">1.2", "1.3.0-beta", false},
+		{">1.2", "1.3.0", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"^1.x", "1.9.9", true},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{">=8 <10", "9.9.0", true},
+		{">=8 <10", "10.0.0", false},
+		{">= 8", "8.0.0", true},
+		{"8.x || 9.x", "9.1.0", true},
+		{"8.x || 9.x", "10.1.0", false},
+		{"1.2.3 - 2.3", "2.3.9", true},
+		{"1.2.3 - 2.3.4", "2.3.5", false},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"<1.2", "1.1.9", true},
+		{"*", "3.0.0", true},
+		{"", "3.0.0", true},
+		{"7.0.8", "7.0.8", true},
+		{"v7.0.8", "7.0.9", false},
+		{">=7.0.0-rc.1", "7.0.0-rc.2", true},
+		{">=7.0.0-rc.1", "7.1.0-rc.1", false},
+	}
+
+	for _, tc := range testCases {
+		r, err := parseNpmRange(tc.rng)
+		if err != nil {
+			t.Errorf("parseNpmRange(%s) returned error: %s", tc.rng, err)
+			continue
+		}
+
+		if got := r.Check(semver.Must(semver.NewVersion(tc.version))); got != tc.want {
+			t.Errorf("parseNpmRange(%s).Check(%s) = %v, want %v", tc.rng, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestParseNpmRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"a.b.c", "1.2.3.4", "^1.2-beta", ">=1.0.0 <1.a"} {
+		if _, err := parseNpmRange(rng); err == nil {
+			t.Errorf("parseNpmRange(%s) should return error", rng)
+		}
+	}
+}
+
+func TestNpmRangeMaxSatisfying(t *testing.T) {
+	versions := []string{"5.0.0", "5.10.0", "5.2.0", "6.0.0", "6.1.0-next.0", "invalid"}
+
+	testCases := []struct {
+		rng  string
+		want string
+		ok   bool
+	}{
+		{"^5.0.0", "5.10.0", true},
+		{">=5", "6.0.0", true},
+		{"5.1.x", "", false},
+		{"^4", "", false},
+	}
+
+	for _, tc := range testCases {
+		r, err := parseNpmRange(tc.rng)
+		if err != nil {
+			t.Fatalf("parseNpmRange(%s) returned error: %s", tc.rng, err)
+		}
+
+		got, ok := r.maxSatisfying(versions)
+		if ok != tc.ok {
+			t.Errorf("maxSatisfying(%s) ok = %v, want %v", tc.rng, ok, tc.ok)
+			continue
+		}
+		if ok && got.Original() != tc.want {
+			t.Errorf("maxSatisfying(%s) = %s, want %s", tc.rng, got.Original(), tc.want)
+		}
+	}
+}
+
+func TestNpmRangeMinVersion(t *testing.T) {
+	testCases := []struct {
+		rng  string
+		want string
+		ok   bool
+	}{
+		{"*", "0.0.0", true},
+		{"^3.0.0", "3.0.0", true},
+		{">=3", "3.0.0", true},
+		{">1.2.3", "1.2.4", true},
+		{">1.2", "1.3.0", true},
+		{">1.2.3-beta", "1.2.3-beta.0", true},
+		{"2.x || ^1.22.0", "1.22.0", true},
+		{">=2 <3 || >=4", "2.0.0", true},
+		{"<1.0.0 >2.0.0", "", false},
+	}
+
+	for _, tc := range testCases {
+		r, err := parseNpmRange(tc.rng)
+		if err != nil {
+			t.Fatalf("parseNpmRange(%s) returned error: %s", tc.rng, err)
+		}
+
+		got, ok := r.minVersion()
+		if ok != tc.ok {
+			t.Errorf("minVersion(%s) ok = %v, want %v", tc.rng, ok, tc.ok)
+			continue
+		}
+		if ok && got.String() != tc.want {
+			t.Errorf("minVersion(%s) = %s, want %s", tc.rng, got, tc.want)
+		}
+	}
+}
+
+func TestVersionSatisfies(t *testing.T) {
+	testCases := []struct {
+		version string
+		spec    string
+		want    bool
+		hasE    bool
+	}{
+		{"8.19.2", "^8.0.0", true, false},
+		{"8.19.2", "8.19.2", true, false},
+		{"6.14.6", ">=7", false, false},
+		{"8.19.2\n", "8.x || 9.x", true, false},
+		{"not-a-version", "^8", false, true},
+		{"8.19.2", "a.b.c", false, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := versionSatisfies(tc.version, tc.spec)
+		if tc.hasE != (err != nil) {
+			t.Errorf("versionSatisfies(%s, %s) error = %v, want error: %v", tc.version, tc.spec, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("versionSatisfies(%s, %s) = %v, want %v", tc.version, tc.spec, got, tc.want)
+		}
+	}
+}
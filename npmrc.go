@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// parseNpmrc parses the key=value pairs of an .npmrc file
+// https://docs.npmjs.com/cli/configuring-npm/npmrc
+func parseNpmrc(content string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i == -1 {
+			values[line] = "true"
+			continue
+		}
+		values[strings.TrimSpace(line[:i])] = strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+	}
+	return values
+}
+
+// readProjectNpmrc returns the config of the .npmrc file in the working directory, if any
+func readProjectNpmrc(workdir string) (map[string]string, error) {
+	return readNpmrc(filepath.Join(workdir, ".npmrc"))
+}
+
+// readNpmrc returns the config of the .npmrc file, empty if the file does not exist
+func readNpmrc(pth string) (map[string]string, error) {
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+	}
+	if !exists {
+		return map[string]string{}, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf(".npmrc file read error: %s", err)
+	}
+	return parseNpmrc(content), nil
+}
+
+var npmrcEnvRefRegexp = regexp.MustCompile(`\$\{([^}?]+)\??\}`)
+
+// readNpmrcConfig returns the merged config of the user and the project .npmrc files, the project config takes precedence.
+// Environment variable references, like ${NPM_TOKEN}, are expanded the same way as npm does.
+func readNpmrcConfig(workdir string) (map[string]string, error) {
+	userConfig := os.Getenv("NPM_CONFIG_USERCONFIG")
+	if userConfig == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %s", err)
+		}
+		userConfig = filepath.Join(home, ".npmrc")
+	}
+
+	config := map[string]string{}
+	for _, pth := range []string{userConfig, filepath.Join(workdir, ".npmrc")} {
+		values, err := readNpmrc(pth)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range values {
+			config[key] = npmrcEnvRefRegexp.ReplaceAllStringFunc(value, func(ref string) string {
+				return os.Getenv(npmrcEnvRefRegexp.FindStringSubmatch(ref)[1])
+			})
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseNpmrc(t *testing.T) {
+	content := `# comment
+; another comment
+engine-strict = true
+registry="https://registry.example.com/"
+//registry.example.com/:_authToken=${NPM_TOKEN}
+save-exact
+`
+	want := map[string]string{
+		"engine-strict":                      "true",
+		"registry":                           "https://registry.example.com/",
+		"//registry.example.com/:_authToken": "${NPM_TOKEN}",
+		"save-exact":                         "true",
+	}
+
+	if got := parseNpmrc(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNpmrc() = %v, want %v", got, want)
+	}
+}
+
+func TestReadNpmrcConfig(t *testing.T) {
+	home, workdir := t.TempDir(), t.TempDir()
+	userConfig := filepath.Join(home, ".npmrc")
+	if err := ioutil.WriteFile(userConfig, []byte("registry=https://user.example.com/\n//user.example.com/:_authToken=${TEST_NPMRC_TOKEN}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(workdir, ".npmrc"), []byte("registry=https://project.example.com/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range map[string]string{"NPM_CONFIG_USERCONFIG": userConfig, "TEST_NPMRC_TOKEN": "secret"} {
+		original, isSet := os.LookupEnv(key)
+		if err := os.Setenv(key, value); err != nil {
+			t.Fatal(err)
+		}
+		defer func(key string) {
+			if isSet {
+				_ = os.Setenv(key, original)
+			} else {
+				_ = os.Unsetenv(key)
+			}
+		}(key)
+	}
+
+	got, err := readNpmrcConfig(workdir)
+	if err != nil {
+		t.Fatalf("readNpmrcConfig() error = %v", err)
+	}
+	want := map[string]string{
+		"registry":                       "https://project.example.com/",
+		"//user.example.com/:_authToken": "secret",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readNpmrcConfig() = %v, want %v", got, want)
+	}
+}
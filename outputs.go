@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-steputils/cache"
+)
+
+const (
+	npmVersionOutputKey         = "NPM_VERSION"
+	nodeVersionOutputKey        = "NODE_VERSION"
+	npmCommandExitCodeOutputKey = "NPM_COMMAND_EXIT_CODE"
+	npmCommandDurationOutputKey = "NPM_COMMAND_DURATION"
+	npmWorkdirOutputKey         = "NPM_WORKDIR"
+)
+
+// stepOutputs are exported for the subsequent steps
+type stepOutputs struct {
+	NpmVersion      string
+	NodeVersion     string
+	CommandExitCode int
+	CommandDuration time.Duration
+	Workdir         string
+}
+
+// exportOutputs exports the outputs with the given setter, see cache.NewEnvmanVariableSetter
+func exportOutputs(setter cache.VariableSetter, outputs stepOutputs) error {
+	values := []struct {
+		key   string
+		value string
+	}{
+		{npmVersionOutputKey, outputs.NpmVersion},
+		{nodeVersionOutputKey, outputs.NodeVersion},
+		{npmCommandExitCodeOutputKey, strconv.Itoa(outputs.CommandExitCode)},
+		{npmCommandDurationOutputKey, strconv.FormatFloat(outputs.CommandDuration.Seconds(), 'f', 2, 64)},
+		{npmWorkdirOutputKey, outputs.Workdir},
+	}
+
+	for _, v := range values {
+		if err := setter.Set(v.key, v.value); err != nil {
+			return fmt.Errorf("failed to export %s: %s", v.key, err)
+		}
+	}
+	return nil
+}
+
+// exitCode returns the exit code of a command run error.
+// A command killed by a signal exits with 128 plus the signal number, like in shells.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal())
+		}
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+type mockVariableSetter map[string]string
+
+func (s mockVariableSetter) Set(key, value string) error {
+	s[key] = value
+	return nil
+}
+
+func TestExportOutputs(t *testing.T) {
+	setter := mockVariableSetter{}
+	outputs := stepOutputs{
+		NpmVersion:      "9.8.1",
+		NodeVersion:     "18.17.0",
+		CommandExitCode: 1,
+		CommandDuration: 1500 * time.Millisecond,
+		Workdir:         "/bitrise/src",
+	}
+
+	if err := exportOutputs(setter, outputs); err != nil {
+		t.Fatalf("exportOutputs returned error: %s", err)
+	}
+
+	want := map[string]string{
+		npmVersionOutputKey:         "9.8.1",
+		nodeVersionOutputKey:        "18.17.0",
+		npmCommandExitCodeOutputKey: "1",
+		npmCommandDurationOutputKey: "1.50",
+		npmWorkdirOutputKey:         "/bitrise/src",
+	}
+	for key, value := range want {
+		if setter[key] != value {
+			t.Errorf("%s = %s, want %s", key, setter[key], value)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	if got := exitCode(nil); got != 0 {
+		t.Errorf("exitCode(nil) = %d, want 0", got)
+	}
+	if got := exitCode(errors.New("executable not found")); got != 1 {
+		t.Errorf("exitCode(error) = %d, want 1", got)
+	}
+	if got := exitCode(exec.Command("sh", "-c", "exit 3").Run()); got != 3 {
+		t.Errorf("exitCode(exit 3) = %d, want 3", got)
+	}
+	if got := exitCode(exec.Command("sh", "-c", "kill -TERM $$").Run()); got != 143 {
+		t.Errorf("exitCode(kill -TERM) = %d, want 143", got)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/fileutil"
+)
+
+// packageJSON holds the package.json fields used by the step
+type packageJSON struct {
+	Engines struct {
+		Npm  string `json:"npm"`
+		Node string `json:"node"`
+		Yarn string `json:"yarn"`
+		Pnpm string `json:"pnpm"`
+		Bun  string `json:"bun"`
+	} `json:"engines"`
+	PackageManager string            `json:"packageManager"`
+	Volta          voltaConfig       `json:"volta"`
+	Scripts        map[string]string `json:"scripts"`
+
+	Dependencies         map[string]string `json:"dependencies"`
+	DevDependencies      map[string]string `json:"devDependencies"`
+	OptionalDependencies map[string]string `json:"optionalDependencies"`
+}
+
+// voltaConfig holds the tool versions pinned by Volta.
+// https://docs.volta.sh/advanced/workspaces
+type voltaConfig struct {
+	Node    string `json:"node"`
+	Npm     string `json:"npm"`
+	Yarn    string `json:"yarn"`
+	Pnpm    string `json:"pnpm"`
+	Extends string `json:"extends"`
+}
+
+func parsePackageJSON(jsonStr string) (packageJSON, error) {
+	var m packageJSON
+	if err := json.Unmarshal([]byte(jsonStr), &m); err != nil {
+		return packageJSON{}, fmt.Errorf("json unmarshal error: %s", err)
+	}
+	return m, nil
+}
+
+// readPackageJSON reads the package.json at path, merging the Volta pins of the files it extends
+func readPackageJSON(path string) (packageJSON, error) {
+	jsonStr, err := fileutil.ReadStringFromFile(path)
+	if err != nil {
+		return packageJSON{}, fmt.Errorf("package.json file read error: %s", err)
+	}
+
+	m, err := parsePackageJSON(jsonStr)
+	if err != nil {
+		return packageJSON{}, fmt.Errorf("failed to parse package.json: %s", err)
+	}
+
+	visited := map[string]bool{}
+	for pth, volta := path, m.Volta; volta.Extends != ""; {
+		if abs, err := filepath.Abs(pth); err == nil {
+			visited[abs] = true
+		}
+
+		pth = filepath.Join(filepath.Dir(pth), volta.Extends)
+		if abs, err := filepath.Abs(pth); err == nil && visited[abs] {
+			return packageJSON{}, fmt.Errorf("circular volta.extends reference to %s", pth)
+		}
+
+		jsonStr, err := fileutil.ReadStringFromFile(pth)
+		if err != nil {
+			return packageJSON{}, fmt.Errorf("volta.extends file read error: %s", err)
+		}
+		parent, err := parsePackageJSON(jsonStr)
+		if err != nil {
+			return packageJSON{}, fmt.Errorf("failed to parse %s: %s", pth, err)
+		}
+
+		volta = parent.Volta
+		for _, pin := range []struct{ child, parent *string }{
+			{&m.Volta.Node, &volta.Node},
+			{&m.Volta.Npm, &volta.Npm},
+			{&m.Volta.Yarn, &volta.Yarn},
+			{&m.Volta.Pnpm, &volta.Pnpm},
+		} {
+			if *pin.child == "" {
+				*pin.child = *pin.parent
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// engine returns the engines requirement of the tool
+func (m packageJSON) engine(tool string) string {
+	switch tool {
+	case "node":
+		return m.Engines.Node
+	case "npm":
+		return m.Engines.Npm
+	case "yarn":
+		return m.Engines.Yarn
+	case "pnpm":
+		return m.Engines.Pnpm
+	case "bun":
+		return m.Engines.Bun
+	}
+	return ""
+}
+
+// pin returns the version of the tool pinned by Volta
+func (v voltaConfig) pin(tool string) string {
+	switch tool {
+	case "node":
+		return v.Node
+	case "npm":
+		return v.Npm
+	case "yarn":
+		return v.Yarn
+	case "pnpm":
+		return v.Pnpm
+	}
+	return ""
+}
+
+// packageManagerSpec is the parsed value of the package.json "packageManager" field,
+// for example `npm@10.2.4+sha512.abc...`.
+// https://nodejs.org/api/corepack.html#configuring-a-package
+type packageManagerSpec struct {
+	Name          string
+	Version       string
+	HashAlgorithm string
+	Hash          string
+}
+
+func parsePackageManager(s string) (packageManagerSpec, error) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return packageManagerSpec{}, fmt.Errorf("`%s` is not in the <name>@<version> format", s)
+	}
+
+	spec := packageManagerSpec{Name: s[:i], Version: s[i+1:]}
+	if j := strings.Index(spec.Version, "+"); j != -1 {
+		hash := spec.Version[j+1:]
+		spec.Version = spec.Version[:j]
+
+		k := strings.Index(hash, ".")
+		if k <= 0 || k == len(hash)-1 {
+			return packageManagerSpec{}, fmt.Errorf("`%s` has an invalid hash, expected <algorithm>.<hex digest>", s)
+		}
+		spec.HashAlgorithm, spec.Hash = hash[:k], hash[k+1:]
+	}
+
+	r, err := parseNpmRange(spec.Version)
+	if err != nil {
+		return packageManagerSpec{}, fmt.Errorf("`%s` has an invalid version: %s", s, err)
+	}
+	if _, ok := r.exactVersion(); !ok {
+		return packageManagerSpec{}, fmt.Errorf("`%s` does not pin an exact version", s)
+	}
+
+	return spec, nil
+}
+
+// versionRequirement is the version of a tool required by the project
+type versionRequirement struct {
+	// Spec is either an exact version or an npm semver range
+	Spec string
+	// HashAlgorithm and Hash are set if the version is pinned with a hash in the packageManager field
+	HashAlgorithm string
+	Hash          string
+	// Source is the package.json field the requirement comes from
+	Source string
+}
+
+// toolRequirementFromPackageJSON returns the version of the package manager tool required by the project.
+// Precedence: the packageManager field, the Volta pin, then engines.
+func toolRequirementFromPackageJSON(m packageJSON, tool string) (versionRequirement, error) {
+	if m.PackageManager != "" {
+		pm, err := parsePackageManager(m.PackageManager)
+		if err != nil {
+			return versionRequirement{}, fmt.Errorf("invalid packageManager field: %s", err)
+		}
+		if pm.Name == tool {
+			return versionRequirement{
+				Spec:          pm.Version,
+				HashAlgorithm: pm.HashAlgorithm,
+				Hash:          pm.Hash,
+				Source:        "packageManager",
+			}, nil
+		}
+	}
+
+	// "bundled" pins the npm shipped with the pinned Node.js
+	if pin := m.Volta.pin(tool); pin != "" && pin != "bundled" {
+		if _, err := parseNpmRange(pin); err != nil {
+			return versionRequirement{}, fmt.Errorf("`%s` is not valid volta.%s version: %s", pin, tool, err)
+		}
+		return versionRequirement{Spec: strings.TrimSpace(pin), Source: "volta." + tool}, nil
+	}
+
+	rng := m.engine(tool)
+	if rng == "" {
+		return versionRequirement{}, nil
+	}
+
+	if _, err := parseNpmRange(rng); err != nil {
+		return versionRequirement{}, fmt.Errorf("`%s` is not valid semver range: %s", rng, err)
+	}
+
+	return versionRequirement{Spec: strings.TrimSpace(rng), Source: "engines." + tool}, nil
+}
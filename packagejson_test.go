@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePackageManager(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  packageManagerSpec
+		hasE  bool
+	}{
+		{"npm@10.2.4", packageManagerSpec{Name: "npm", Version: "10.2.4"}, false},
+		{"npm@10.2.4+sha512.abc123", packageManagerSpec{Name: "npm", Version: "10.2.4", HashAlgorithm: "sha512", Hash: "abc123"}, false},
+		{"@scope/pm@1.0.0", packageManagerSpec{Name: "@scope/pm", Version: "1.0.0"}, false},
+		{"npm", packageManagerSpec{}, true},
+		{"npm@^10", packageManagerSpec{}, true},
+		{"npm@10.2.4+sha512", packageManagerSpec{}, true},
+		{"npm@10.2.4+sha512.", packageManagerSpec{}, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parsePackageManager(tc.value)
+		if tc.hasE != (err != nil) {
+			t.Errorf("parsePackageManager(%s) error = %v, want error: %v", tc.value, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("parsePackageManager(%s) = %+v, want %+v", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestReadPackageJSONVoltaExtends(t *testing.T) {
+	dir := writeProjectFiles(t, map[string]string{
+		"package.json":         `{"volta":{"node":"16.20.2","npm":"8.19.4"}}`,
+		"base/package.json":    `{"volta":{"node":"18.17.0","extends":"../package.json"}}`,
+		"app/package.json":     `{"volta":{"extends":"../base/package.json"}}`,
+		"cycle/a/package.json": `{"volta":{"extends":"../b/package.json"}}`,
+		"cycle/b/package.json": `{"volta":{"extends":"../a/package.json"}}`,
+		"missing/package.json": `{"volta":{"extends":"../nope/package.json"}}`,
+	})
+
+	m, err := readPackageJSON(filepath.Join(dir, "app/package.json"))
+	if err != nil {
+		t.Fatalf("readPackageJSON returned error: %s", err)
+	}
+	if m.Volta.Node != "18.17.0" || m.Volta.Npm != "8.19.4" {
+		t.Errorf("readPackageJSON volta = %+v, want node 18.17.0 and npm 8.19.4", m.Volta)
+	}
+
+	if _, err := readPackageJSON(filepath.Join(dir, "cycle/a/package.json")); err == nil {
+		t.Errorf("readPackageJSON should fail for circular extends")
+	}
+	if _, err := readPackageJSON(filepath.Join(dir, "missing/package.json")); err == nil {
+		t.Errorf("readPackageJSON should fail for a missing extended file")
+	}
+}
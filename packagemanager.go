@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// packageManager runs the user provided npm command with the package manager of the project
+type packageManager interface {
+	Name() string
+	// Setup makes the required version of the package manager available
+	Setup() error
+	// Command returns the command line (executable and arguments) equivalent to the npm command arguments
+	Command(npmArgs []string) []string
+	// Cache marks the dependencies installed by the package manager for caching
+	Cache() error
+}
+
+// npmManager runs the command with npm, which is set up by the step before any other package manager
+type npmManager struct {
+	workdir string
+}
+
+func (npmManager) Name() string {
+	return "npm"
+}
+
+func (npmManager) Setup() error {
+	return nil
+}
+
+func (npmManager) Command(npmArgs []string) []string {
+	return append([]string{"npm"}, npmArgs...)
+}
+
+func (m npmManager) Cache() error {
+	return cacheNpm(m.workdir)
+}
+
+// withoutScriptArgsSeparator removes the -- separator npm requires between the script name and the script arguments
+// of npm run, Yarn and pnpm pass the arguments after the script name through without it
+func withoutScriptArgsSeparator(runArgs []string) []string {
+	if len(runArgs) > 1 && runArgs[1] == "--" {
+		return append([]string{runArgs[0]}, runArgs[2:]...)
+	}
+	return runArgs
+}
+
+// toolProvisioner makes the package manager versions required by the project available
+type toolProvisioner struct {
+	workdir  string
+	registry registryClient
+	tools    toolCache
+	corepack bool
+	isolated bool
+}
+
+// provision makes the version of the tool satisfying the requirement available: by Corepack if the version is pinned
+// in the packageManager field, the preinstalled one if it satisfies the requirement, or by installing the registry package.
+// Without requirement the preinstalled version is used, the latest one is installed if there is none.
+func (p toolProvisioner) provision(name, pkg string, req versionRequirement) error {
+	if p.corepack && req.Source == "packageManager" {
+		log.Printf("%s version %s is provisioned by Corepack", name, req.Spec)
+		return enableCorepack(name)
+	}
+
+	installedVer, err := toolVersion(name, p.workdir)
+	if err != nil {
+		return err
+	}
+	if installedVer != "" {
+		log.Printf("Preinstalled %s version: %s", name, installedVer)
+
+		if req.Spec == "" {
+			log.Donef("No %s version requirement found, using the preinstalled %s", name, name)
+			return nil
+		}
+		satisfied, err := versionSatisfies(installedVer, req.Spec)
+		if err != nil {
+			log.Warnf("Failed to compare preinstalled %s version with `%s`: %s", name, req.Spec, err)
+		} else if satisfied {
+			log.Donef("Preinstalled %s version %s satisfies `%s`, skipping %s install", name, installedVer, req.Spec, name)
+			return nil
+		}
+	} else if req.Spec == "" {
+		req = versionRequirement{Spec: "latest", Source: "default"}
+	}
+
+	ver, err := resolveWithToolCache(p.tools, pkg, req.Spec, func(spec string) (string, error) {
+		return p.registry.resolveVersion(pkg, spec)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s version `%s`: %s", name, req.Spec, err)
+	}
+	log.Printf("Installing %s version %s", name, ver)
+
+	return installRegistryTool(p.registry, p.tools, pkg, req, ver, p.isolated)
+}
+
+// toolVersion returns the version of the tool on PATH, empty if the tool is not installed
+func toolVersion(name, workdir string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", nil
+	}
+
+	cmd := command.New(name, "--version")
+	cmd.SetDir(workdir)
+	log.Donef("$ %s", cmd.PrintableCommandArgs())
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return "", fmt.Errorf("%s command failed: %s", name, out)
+		}
+		return "", fmt.Errorf("error running %s command: %s", name, err)
+	}
+	return out, nil
+}
+
+// packageManagerRequirement returns the version of the package manager required by the package.json in the working directory.
+// In strict mode invalid requirements are errors, otherwise they are logged and ignored.
+func packageManagerRequirement(workdir, name string, strict bool) (versionRequirement, error) {
+	pth := filepath.Join(workdir, "package.json")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return versionRequirement{}, fmt.Errorf("failed to check if package.json exists: %s", err)
+	}
+	if !exists {
+		return versionRequirement{}, nil
+	}
+
+	req := versionRequirement{}
+	m, err := readPackageJSON(pth)
+	if err == nil {
+		req, err = toolRequirementFromPackageJSON(m, name)
+	}
+	if err != nil {
+		if strict {
+			return versionRequirement{}, fmt.Errorf("failed to get %s version: %s", name, err)
+		}
+		log.Warnf("error getting %s version: %s", name, err)
+		return versionRequirement{}, nil
+	}
+
+	if req.Spec != "" {
+		log.Printf("%s version `%s` required by %s", name, req.Spec, req.Source)
+	}
+	return req, nil
+}
+
+// newPackageManager returns the package manager with the given name
+func newPackageManager(name string, p toolProvisioner, strict bool, bunDownloadURL string) (packageManager, error) {
+	switch name {
+	case "npm":
+		return npmManager{workdir: p.workdir}, nil
+	case "yarn":
+		return newYarnManager(p, strict)
+	case "pnpm":
+		return newPnpmManager(p, strict)
+	case "bun":
+		return newBunManager(p, strict, bunDownloadURL)
+	}
+	return nil, fmt.Errorf("unknown package manager: %s", name)
+}
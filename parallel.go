@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// prefixWriter writes every line prefixed to the shared output, whole lines are written at once
+// so that the output of concurrent commands is not interleaved within a line
+type prefixWriter struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i == -1 {
+			break
+		}
+		if err := w.writeLine(w.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the last line, if it was not terminated by a newline
+func (w *prefixWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := append(w.buf, '\n')
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.out.Write(append([]byte(w.prefix), line...))
+	return err
+}
+
+// commandLabel returns the short name of the npm command used to prefix its output, the script name for run commands
+func commandLabel(npmArgs []string) string {
+	if len(npmArgs) > 1 && (npmArgs[0] == "run" || npmArgs[0] == "run-script") {
+		return npmArgs[1]
+	}
+	return strings.Join(npmArgs, " ")
+}
+
+// parallelRunner runs the commands concurrently with a bounded number of workers
+type parallelRunner struct {
+	pm       packageManager
+	workdir  string
+	workers  int
+	failFast bool
+	// grouped prints the output of a command at once when it finished, instead of streaming the prefixed lines
+	grouped bool
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func newParallelRunner(pm packageManager, workdir string, workers int, failFast, grouped bool) *parallelRunner {
+	if workers < 1 {
+		workers = 1
+	}
+	return &parallelRunner{
+		pm:       pm,
+		workdir:  workdir,
+		workers:  workers,
+		failFast: failFast,
+		grouped:  grouped,
+		out:      stdout,
+	}
+}
+
+// run runs the commands and returns their results in the order of the commands.
+// In fail-fast mode a failure cancels the running commands and skips the ones not started yet.
+func (r *parallelRunner) run(commands [][]string) []commandResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fmt.Println()
+	log.Infof("Running %d user provided commands, %d at a time", len(commands), r.workers)
+
+	results := make([]commandResult, len(commands))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < r.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					cmdLine := newUserCommand(r.pm, r.workdir, commands[i]).PrintableCommandArgs()
+					results[i] = commandResult{npmArgs: commands[i], cmdLine: cmdLine, skipped: true}
+					continue
+				}
+
+				results[i] = r.runCommand(ctx, commands[i])
+				if results[i].err != nil && !results[i].canceled && r.failFast {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	for i := range commands {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// runCommand runs the command, retrying it if it fails with a transient network error.
+// The retries are abandoned if the command is canceled.
+func (r *parallelRunner) runCommand(ctx context.Context, npmArgs []string) commandResult {
+	label := commandLabel(npmArgs)
+	result := commandResult{npmArgs: npmArgs}
+
+	retry := networkRetry
+	retry.sleep = func(d time.Duration) {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+	}
+
+	start := time.Now()
+	result.err = retry.run(fmt.Sprintf("[%s]", label), func() (string, error) {
+		if err := ctx.Err(); err != nil {
+			result.canceled = true
+			return "", err
+		}
+		return r.runAttempt(ctx, npmArgs, label, &result)
+	})
+	result.duration = time.Since(start)
+
+	r.write(fmt.Sprintf("[%s] %s in %s\n", label, result.status(), result.duration.Round(10*time.Millisecond)))
+
+	return result
+}
+
+// runAttempt runs the command in its own process group, so that the processes started by the scripts
+// are terminated too if the command is canceled. Returns the end of the command output.
+func (r *parallelRunner) runAttempt(ctx context.Context, npmArgs []string, label string, result *commandResult) (string, error) {
+	cmdArgs := r.pm.Command(npmArgs)
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	cmd.Dir = r.workdir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	result.cmdLine = command.NewWithCmd(cmd).PrintableCommandArgs()
+
+	var group bytes.Buffer
+	out := &prefixWriter{mu: &r.mu, out: r.out, prefix: fmt.Sprintf("[%s] ", label)}
+	if r.grouped {
+		out = &prefixWriter{mu: &sync.Mutex{}, out: &group}
+	}
+	tail := newTailBuffer(outputTailSize)
+	cmd.Stdout = io.MultiWriter(out, tail)
+	cmd.Stderr = cmd.Stdout
+
+	r.write(fmt.Sprintf("[%s] $ %s\n", label, result.cmdLine))
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM); err != nil {
+			log.Warnf("Failed to terminate %s: %s", result.cmdLine, err)
+		}
+		err = <-done
+		result.canceled = err != nil
+	}
+
+	if err := out.Flush(); err != nil {
+		log.Warnf("Failed to write output of %s: %s", result.cmdLine, err)
+	}
+	if r.grouped {
+		r.write(fmt.Sprintf("[%s] output of %s:\n%s", label, result.cmdLine, group.String()))
+	}
+
+	if result.canceled {
+		// the output of a terminated command is not a reason to retry
+		return "", err
+	}
+	return tail.String(), err
+}
+
+// write writes to the shared output
+func (r *parallelRunner) write(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := io.WriteString(r.out, s); err != nil {
+		log.Warnf("Failed to write output: %s", err)
+	}
+}
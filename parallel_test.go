@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &prefixWriter{mu: &sync.Mutex{}, out: &out, prefix: "[lint] "}
+
+	for _, s := range []string{"first line\nsec", "ond line\n", "no newline"} {
+		if _, err := w.Write([]byte(s)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := "[lint] first line\n[lint] second line\n[lint] no newline\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestCommandLabel(t *testing.T) {
+	testCases := []struct {
+		npmArgs []string
+		want    string
+	}{
+		{[]string{"run", "lint"}, "lint"},
+		{[]string{"run-script", "typecheck", "--", "--pretty"}, "typecheck"},
+		{[]string{"test"}, "test"},
+		{[]string{"install", "-g", "cordova"}, "install -g cordova"},
+	}
+
+	for _, tc := range testCases {
+		if got := commandLabel(tc.npmArgs); got != tc.want {
+			t.Errorf("commandLabel(%v) = %s, want %s", tc.npmArgs, got, tc.want)
+		}
+	}
+}
+
+func TestParallelRunner(t *testing.T) {
+	testCases := []struct {
+		name     string
+		commands [][]string
+		workers  int
+		failFast bool
+		grouped  bool
+		want     []string
+		wantCode int
+	}{
+		{
+			name:     "all succeed",
+			commands: [][]string{{"echo one"}, {"echo two"}, {"echo three"}},
+			workers:  2,
+			want:     []string{"succeeded", "succeeded", "succeeded"},
+		},
+		{
+			name:     "fail fast cancels the running and skips the queued commands",
+			commands: [][]string{{"sleep 10"}, {"exit 2"}, {"sleep 10"}},
+			workers:  2,
+			failFast: true,
+			want:     []string{"canceled", "failed (exit code 2)", "skipped"},
+			wantCode: 2,
+		},
+		{
+			name:     "run all",
+			commands: [][]string{{"exit 2"}, {"echo done"}},
+			workers:  2,
+			grouped:  true,
+			want:     []string{"failed (exit code 2)", "succeeded"},
+			wantCode: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := newParallelRunner(shellManager{}, t.TempDir(), tc.workers, tc.failFast, tc.grouped)
+			r.out = &out
+
+			start := time.Now()
+			results := r.run(tc.commands)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("run() took %s, the commands were not canceled", elapsed)
+			}
+
+			var statuses []string
+			for _, res := range results {
+				statuses = append(statuses, res.status())
+			}
+			if !reflect.DeepEqual(statuses, tc.want) {
+				t.Errorf("run() = %v, want %v", statuses, tc.want)
+			}
+			if code := commandExitCode(results); code != tc.wantCode {
+				t.Errorf("commandExitCode() = %d, want %d", code, tc.wantCode)
+			}
+			if tc.grouped && !strings.Contains(out.String(), "[echo done] output of sh \"-c\" \"echo done\":\ndone\n") {
+				t.Errorf("grouped output missing, got:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestRunUserCommandsParallelDuration(t *testing.T) {
+	commands := [][]string{{"sleep 0.5"}, {"sleep 0.5"}, {"sleep 0.5"}}
+	config := Config{Parallel: true, MaxParallel: 3, FailureMode: "fail-fast"}
+
+	results, duration := runUserCommands(shellManager{}, t.TempDir(), commands, config)
+
+	var total time.Duration
+	for _, r := range results {
+		total += r.duration
+	}
+	if duration >= total {
+		t.Errorf("runUserCommands() duration = %s, want the wall-clock duration shorter than the total %s", duration, total)
+	}
+	if duration < 500*time.Millisecond {
+		t.Errorf("runUserCommands() duration = %s, want at least the duration of one command", duration)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// pnpmManager runs the command with pnpm, installed from the registry like npm
+type pnpmManager struct {
+	toolProvisioner
+	req versionRequirement
+}
+
+func newPnpmManager(p toolProvisioner, strict bool) (pnpmManager, error) {
+	req, err := packageManagerRequirement(p.workdir, "pnpm", strict)
+	if err != nil {
+		return pnpmManager{}, err
+	}
+	return pnpmManager{toolProvisioner: p, req: req}, nil
+}
+
+func (pnpmManager) Name() string {
+	return "pnpm"
+}
+
+func (m pnpmManager) Setup() error {
+	return m.provision("pnpm", "pnpm", m.req)
+}
+
+func (pnpmManager) Command(npmArgs []string) []string {
+	return append([]string{"pnpm"}, pnpmArgs(npmArgs)...)
+}
+
+// Cache marks the pnpm content-addressable store for caching, with pnpm-lock.yaml as the indicator.
+// node_modules only has links into the store, it is not cached.
+func (m pnpmManager) Cache() error {
+	cmd := command.New("pnpm", "store", "path")
+	cmd.SetDir(m.workdir)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get pnpm store path: %s", out)
+	}
+
+	return cachePnpm(m.workdir, out)
+}
+
+// pnpmArgs translates npm command arguments to the equivalent pnpm arguments.
+// pnpm accepts most npm commands, packages are added with pnpm add instead of pnpm install.
+// https://pnpm.io/cli/install
+func pnpmArgs(npmArgs []string) []string {
+	if len(npmArgs) == 0 {
+		return []string{"install"}
+	}
+
+	cmd, rest := npmArgs[0], npmArgs[1:]
+	switch cmd {
+	case "install", "isntall", "i", "add":
+		var args []string
+		hasPkgs := false
+		for i := 0; i < len(rest); i++ {
+			arg := rest[i]
+			switch {
+			case arg == "-S" || arg == "--save":
+				// pnpm add saves to dependencies by default
+				continue
+			case !strings.HasPrefix(arg, "-"):
+				hasPkgs = true
+			case i+1 < len(rest) && sliceutil.IsStringInSlice(arg, npmValueFlags):
+				args = append(args, arg)
+				i++
+				arg = rest[i]
+			}
+			args = append(args, arg)
+		}
+
+		if hasPkgs {
+			return append([]string{"add"}, args...)
+		}
+		return append([]string{"install"}, args...)
+	case "ci":
+		return append([]string{"install", "--frozen-lockfile"}, rest...)
+	case "run", "run-script", "rum", "urn":
+		return append([]string{"run"}, withoutScriptArgsSeparator(rest)...)
+	case "uninstall", "rm", "r", "un":
+		return append([]string{"remove"}, rest...)
+	}
+	return npmArgs
+}
+
+// cachePnpm marks the pnpm store for caching, with pnpm-lock.yaml as the indicator
+func cachePnpm(workdir, storePath string) error {
+	return cacheDependencyPaths([]string{storePath}, filepath.Join(workdir, "pnpm-lock.yaml"))
+}
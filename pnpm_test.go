@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPnpmArgs(t *testing.T) {
+	testCases := []struct {
+		npmArgs []string
+		want    []string
+	}{
+		{nil, []string{"install"}},
+		{[]string{"install"}, []string{"install"}},
+		{[]string{"ci"}, []string{"install", "--frozen-lockfile"}},
+		{[]string{"install", "--save", "lodash"}, []string{"add", "lodash"}},
+		{[]string{"i", "-D", "jest", "--registry", "https://registry.example.com"}, []string{"add", "-D", "jest", "--registry", "https://registry.example.com"}},
+		{[]string{"install", "--registry", "https://registry.example.com"}, []string{"install", "--registry", "https://registry.example.com"}},
+		{[]string{"install", "-g", "typescript"}, []string{"add", "-g", "typescript"}},
+		{[]string{"run-script", "build"}, []string{"run", "build"}},
+		{[]string{"run", "lint", "--", "--fix"}, []string{"run", "lint", "--fix"}},
+		{[]string{"test"}, []string{"test"}},
+		{[]string{"uninstall", "lodash"}, []string{"remove", "lodash"}},
+	}
+
+	for _, tc := range testCases {
+		if got := pnpmArgs(tc.npmArgs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("pnpmArgs(%v) = %v, want %v", tc.npmArgs, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const defaultNpmRegistry = "https://registry.npmjs.org/"
+
+// packageMetadata is the abbreviated package document served by npm registries.
+// https://github.com/npm/registry/blob/master/docs/responses/package-metadata.md
+type packageMetadata struct {
+	DistTags map[string]string         `json:"dist-tags"`
+	Versions map[string]packageVersion `json:"versions"`
+}
+
+type packageVersion struct {
+	Version string `json:"version"`
+	Dist    struct {
+		Tarball   string `json:"tarball"`
+		Shasum    string `json:"shasum"`
+		Integrity string `json:"integrity"`
+	} `json:"dist"`
+}
+
+func (m packageMetadata) versionList() []string {
+	var versions []string
+	for v := range m.Versions {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+type registryClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// newRegistryClient returns the client of the registry, which sends the credentials and uses the TLS settings of the npm config
+func newRegistryClient(baseURL string, npmrc map[string]string) (registryClient, error) {
+	if baseURL == "" {
+		baseURL = defaultNpmRegistry
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/") + "/"
+
+	transport, err := npmrcTransport(npmrc)
+	if err != nil {
+		return registryClient{}, err
+	}
+	return registryClient{
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout:   5 * time.Minute,
+			Transport: registryAuth{registry: baseURL, npmrc: npmrc, base: transport},
+		},
+	}, nil
+}
+
+// npmConfigRegistry returns the registry npm uses in the working directory, set in the .npmrc files or the env,
+// empty if npm is not available
+func npmConfigRegistry(workdir string) string {
+	if _, err := exec.LookPath("npm"); err != nil {
+		return ""
+	}
+
+	cmd := command.New("npm", "config", "get", "registry")
+	cmd.SetDir(workdir)
+	out, err := cmd.RunAndReturnTrimmedOutput()
+	if err != nil {
+		log.Warnf("Failed to get npm registry: %s", out)
+		return ""
+	}
+	if u, err := url.Parse(out); err != nil || u.Host == "" {
+		return ""
+	}
+	return out
+}
+
+// npmrcTransport returns the HTTP transport using the cafile and strict-ssl settings of the npm config
+func npmrcTransport(npmrc map[string]string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{}
+	if npmrc["strict-ssl"] == "false" {
+		tlsConfig.InsecureSkipVerify = true
+	}
+	if cafile := npmrc["cafile"]; cafile != "" {
+		pem, err := ioutil.ReadFile(cafile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cafile: %s", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in cafile %s", cafile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// registryAuth adds the credentials of the npm config to the requests, for the URLs the credentials are scoped to
+// https://docs.npmjs.com/cli/configuring-npm/npmrc#auth-related-configuration
+type registryAuth struct {
+	registry string
+	npmrc    map[string]string
+	base     http.RoundTripper
+}
+
+func (a registryAuth) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" {
+		if auth := a.authorization(req.URL); auth != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", auth)
+		}
+	}
+	return a.base.RoundTrip(req)
+}
+
+// authorization returns the Authorization header value of the URL: the credentials of the longest matching
+// //<host>/<path>/: prefix, or the unscoped credentials for the URLs of the registry
+func (a registryAuth) authorization(u *url.URL) string {
+	dir := u.Path[:strings.LastIndex(u.Path, "/")+1]
+	if dir == "" {
+		dir = "/"
+	}
+	for {
+		prefix := "//" + u.Host + dir
+		for _, key := range []string{prefix + ":", strings.TrimSuffix(prefix, "/") + ":"} {
+			if auth := a.credentials(key); auth != "" {
+				return auth
+			}
+		}
+		if dir == "/" {
+			break
+		}
+		dir = dir[:strings.LastIndex(strings.TrimSuffix(dir, "/"), "/")+1]
+	}
+
+	if r, err := url.Parse(a.registry); err == nil && r.Host == u.Host {
+		return a.credentials("")
+	}
+	return ""
+}
+
+// credentials returns the Authorization header value of the auth configs with the key prefix
+func (a registryAuth) credentials(prefix string) string {
+	if token := a.npmrc[prefix+"_authToken"]; token != "" {
+		return "Bearer " + token
+	}
+	if auth := a.npmrc[prefix+"_auth"]; auth != "" {
+		return "Basic " + auth
+	}
+	username, password := a.npmrc[prefix+"username"], a.npmrc[prefix+"_password"]
+	if username != "" && password != "" {
+		// _password is base64 encoded
+		decoded, err := base64.StdEncoding.DecodeString(password)
+		if err != nil {
+			return ""
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+string(decoded)))
+	}
+	return ""
+}
+
+func (c registryClient) packageMetadata(name string) (packageMetadata, error) {
+	u := c.baseURL + url.PathEscape(name)
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return packageMetadata{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json; q=1.0, application/json; q=0.8")
+
+	body, err := fetch(c.client, req)
+	if err != nil {
+		return packageMetadata{}, err
+	}
+
+	var m packageMetadata
+	if err := json.Unmarshal(body, &m); err != nil {
+		return packageMetadata{}, fmt.Errorf("failed to parse package metadata of %s: %s", name, err)
+	}
+	return m, nil
+}
+
+// resolveVersion returns the version the dist-tag points to,
+// or the highest published version of the package satisfying the given npm range.
+func (c registryClient) resolveVersion(name, spec string) (string, error) {
+	if isDistTag(spec) {
+		return c.resolveDistTag(name, spec)
+	}
+
+	r, err := parseNpmRange(spec)
+	if err != nil {
+		return "", err
+	}
+	if v, ok := r.exactVersion(); ok {
+		return v.String(), nil
+	}
+
+	m, err := c.packageMetadata(name)
+	if err != nil {
+		return "", err
+	}
+	v, ok := r.maxSatisfying(m.versionList())
+	if !ok {
+		return "", fmt.Errorf("no published %s version satisfies `%s`", name, spec)
+	}
+	return v.Original(), nil
+}
+
+// resolveDistTag returns the version the dist-tag (like latest, next or latest-9) points to
+func (c registryClient) resolveDistTag(name, tag string) (string, error) {
+	m, err := c.packageMetadata(name)
+	if err != nil {
+		return "", err
+	}
+
+	v, ok := m.DistTags[tag]
+	if !ok {
+		var tags []string
+		for t := range m.DistTags {
+			tags = append(tags, t)
+		}
+		sort.Strings(tags)
+		return "", fmt.Errorf("%s has no dist-tag `%s`, available dist-tags: %s", name, tag, strings.Join(tags, ", "))
+	}
+	return v, nil
+}
+
+var distTagRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// isDistTag reports whether the version spec is a dist-tag rather than a version or a range
+func isDistTag(spec string) bool {
+	if !distTagRegexp.MatchString(spec) {
+		return false
+	}
+	_, err := parseNpmRange(spec)
+	return err != nil
+}
+
+// downloadTarball downloads the tarball of the given package version into dir and returns its path.
+func (c registryClient) downloadTarball(name, version, dir string) (string, error) {
+	m, err := c.packageMetadata(name)
+	if err != nil {
+		return "", err
+	}
+	v, ok := m.Versions[version]
+	if !ok || v.Dist.Tarball == "" {
+		return "", fmt.Errorf("%s version %s is not published to the registry", name, version)
+	}
+
+	pth := filepath.Join(dir, tarballFileName(name, version))
+	if err := downloadFile(c.client, v.Dist.Tarball, pth); err != nil {
+		return "", err
+	}
+	return pth, nil
+}
+
+// tarballFileName returns the file name of the package version tarball, as npm pack names it:
+// the scope of scoped packages is joined with a dash, like yarnpkg-cli-dist-4.0.2.tgz
+func tarballFileName(name, version string) string {
+	name = strings.Replace(strings.TrimPrefix(name, "@"), "/", "-", 1)
+	return fmt.Sprintf("%s-%s.tgz", name, version)
+}
+
+// fetch returns the response body of the request, retrying it on connection and server errors
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	u := req.URL.String()
+	var body []byte
+	err := networkRetry.runHTTP(req.Method+" "+u, func() error {
+		resp, err := client.Do(req)
+		if err != nil {
+			return transientHTTPError{reason: "connection error", err: fmt.Errorf("failed to fetch %s: %s", u, err)}
+		}
+		defer func() {
+			if err := resp.Body.Close(); err != nil {
+				log.Warnf("Failed to close response body: %s", err)
+			}
+		}()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return transientHTTPError{reason: "connection error", err: fmt.Errorf("failed to read response of %s: %s", u, err)}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return httpStatusError(u, resp.StatusCode)
+		}
+		body = b
+		return nil
+	})
+	return body, err
+}
+
+// downloadFile downloads the URL to pth, retrying it on connection and server errors
+func downloadFile(client *http.Client, u, pth string) error {
+	return networkRetry.runHTTP("GET "+u, func() error {
+		return downloadFileOnce(client, u, pth)
+	})
+}
+
+func downloadFileOnce(client *http.Client, u, pth string) error {
+	resp, err := client.Get(u)
+	if err != nil {
+		return transientHTTPError{reason: "connection error", err: fmt.Errorf("failed to download %s: %s", u, err)}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body: %s", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return httpStatusError(u, resp.StatusCode)
+	}
+
+	// download next to the destination first, so that an interrupted download does not leave a broken file behind
+	tmpPth := pth + ".download"
+	f, err := os.Create(tmpPth)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return transientHTTPError{reason: "connection error", err: fmt.Errorf("failed to download %s: %s", u, err)}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPth, pth)
+}
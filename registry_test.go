@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRegistry(t *testing.T, packages map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := packages[r.URL.Path[1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if _, err := fmt.Fprint(w, doc); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const testNpmPackageDoc = `{
+	"dist-tags": {"latest": "8.19.2", "next-9": "9.0.0-pre.1"},
+	"versions": {
+		"5.6.0": {"version": "5.6.0"},
+		"5.10.0": {"version": "5.10.0"},
+		"8.19.2": {"version": "8.19.2"},
+		"9.0.0-pre.1": {"version": "9.0.0-pre.1"}
+	}
+}`
+
+func TestRegistryClientResolveVersion(t *testing.T) {
+	server := newTestRegistry(t, map[string]string{"npm": testNpmPackageDoc})
+	client := newTestRegistryClient(t, server.URL, nil)
+
+	testCases := []struct {
+		spec string
+		want string
+		hasE bool
+	}{
+		{"^5.0.0", "5.10.0", false},
+		{">=5 <9", "8.19.2", false},
+		{"5.x || 9.x", "5.10.0", false},
+		{"7.0.8", "7.0.8", false},
+		{"latest", "8.19.2", false},
+		{"next-9", "9.0.0-pre.1", false},
+		{"lts", "", true},
+		{"^6", "", true},
+		{"not-a-range", "", true},
+	}
+
+	for _, tc := range testCases {
+		got, err := client.resolveVersion("npm", tc.spec)
+		if tc.hasE != (err != nil) {
+			t.Errorf("resolveVersion(%s) error = %v, want error: %v", tc.spec, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("resolveVersion(%s) = %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestRegistryClientPackageNotFound(t *testing.T) {
+	server := newTestRegistry(t, map[string]string{})
+
+	if _, err := newTestRegistryClient(t, server.URL, nil).packageMetadata("npm"); err == nil {
+		t.Errorf("packageMetadata should fail for a missing package")
+	}
+}
+
+func TestRegistryClientDownloadTarball(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		switch r.URL.Path {
+		case "/npm":
+			_, err = fmt.Fprintf(w, `{"versions": {"10.2.4": {"dist": {"tarball": "%s/npm/-/npm-10.2.4.tgz"}}}}`, server.URL)
+		case "/npm/-/npm-10.2.4.tgz":
+			_, err = fmt.Fprint(w, "hello\n")
+		default:
+			http.NotFound(w, r)
+		}
+		if err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestRegistryClient(t, server.URL, nil)
+	pth, err := client.downloadTarball("npm", "10.2.4", t.TempDir())
+	if err != nil {
+		t.Fatalf("downloadTarball returned error: %s", err)
+	}
+	if err := verifyFileHash(pth, "sha256", "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03"); err != nil {
+		t.Errorf("downloaded tarball content mismatch: %s", err)
+	}
+
+	if _, err := client.downloadTarball("npm", "9.0.0", t.TempDir()); err == nil {
+		t.Errorf("downloadTarball should fail for an unpublished version")
+	}
+}
+
+func TestIsDistTag(t *testing.T) {
+	testCases := []struct {
+		spec string
+		want bool
+	}{
+		{"latest", true},
+		{"next", true},
+		{"latest-9", true},
+		{"lts", true},
+		{"9.8.1", false},
+		{"^9", false},
+		{"x", false},
+		{">=8 <10", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isDistTag(tc.spec); got != tc.want {
+			t.Errorf("isDistTag(%s) = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestTarballFileName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		version string
+		want    string
+	}{
+		{"npm", "9.8.1", "npm-9.8.1.tgz"},
+		{"@yarnpkg/cli-dist", "4.0.2", "yarnpkg-cli-dist-4.0.2.tgz"},
+	}
+
+	for _, tc := range testCases {
+		if got := tarballFileName(tc.name, tc.version); got != tc.want {
+			t.Errorf("tarballFileName(%s, %s) = %s, want %s", tc.name, tc.version, got, tc.want)
+		}
+	}
+}
+
+func newTestRegistryClient(t *testing.T, baseURL string, npmrc map[string]string) registryClient {
+	client, err := newRegistryClient(baseURL, npmrc)
+	if err != nil {
+		t.Fatalf("newRegistryClient() error = %v", err)
+	}
+	return client
+}
+
+func TestRegistryAuthorization(t *testing.T) {
+	auth := registryAuth{
+		registry: "https://registry.example.com/",
+		npmrc: map[string]string{
+			"//registry.example.com/:_authToken":         "root-token",
+			"//npm.example.com/repo/npm/:_authToken":     "repo-token",
+			"//basic.example.com/:username":              "user",
+			"//basic.example.com/:_password":             "cGFzcw==",
+			"//legacy.example.com:_auth":                 "dXNlcjpwYXNz",
+			"_authToken":                                 "unscoped-token",
+			"//npm.example.com/other/:_authToken":        "other-token",
+			"//registry.example.com/private/:_authToken": "private-token",
+		},
+	}
+
+	testCases := []struct {
+		url  string
+		want string
+	}{
+		{"https://registry.example.com/npm", "Bearer root-token"},
+		{"https://registry.example.com/private/npm", "Bearer private-token"},
+		{"https://npm.example.com/repo/npm/npm/-/npm-10.2.4.tgz", "Bearer repo-token"},
+		{"https://npm.example.com/npm", ""},
+		{"https://basic.example.com/npm", "Basic dXNlcjpwYXNz"},
+		{"https://legacy.example.com/npm", "Basic dXNlcjpwYXNz"},
+		{"https://cdn.example.com/npm-10.2.4.tgz", ""},
+	}
+
+	for _, tc := range testCases {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := auth.authorization(u); got != tc.want {
+			t.Errorf("authorization(%s) = %s, want %s", tc.url, got, tc.want)
+		}
+	}
+
+	auth.npmrc = map[string]string{"_authToken": "unscoped-token"}
+	u, _ := url.Parse("https://registry.example.com/npm")
+	if got := auth.authorization(u); got != "Bearer unscoped-token" {
+		t.Errorf("authorization() with unscoped token = %s, want Bearer unscoped-token", got)
+	}
+}
+
+func TestRegistryClientSendsAuth(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var err error
+		switch r.URL.Path {
+		case "/npm":
+			_, err = fmt.Fprintf(w, `{"versions": {"10.2.4": {"dist": {"tarball": "%s/npm/-/npm-10.2.4.tgz"}}}}`, server.URL)
+		case "/npm/-/npm-10.2.4.tgz":
+			_, err = fmt.Fprint(w, "hello\n")
+		default:
+			http.NotFound(w, r)
+		}
+		if err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	defer server.Close()
+
+	nerfDart := "//" + strings.TrimPrefix(server.URL, "http://") + "/:_authToken"
+	client := newTestRegistryClient(t, server.URL, map[string]string{nerfDart: "secret"})
+	if _, err := client.downloadTarball("npm", "10.2.4", t.TempDir()); err != nil {
+		t.Errorf("downloadTarball() error = %v", err)
+	}
+
+	if _, err := newTestRegistryClient(t, server.URL, nil).packageMetadata("npm"); err == nil {
+		t.Errorf("packageMetadata() without credentials should fail")
+	}
+}
+
+func TestNpmrcTransport(t *testing.T) {
+	transport, err := npmrcTransport(map[string]string{"strict-ssl": "false"})
+	if err != nil {
+		t.Fatalf("npmrcTransport() error = %v", err)
+	}
+	if !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("npmrcTransport() with strict-ssl=false verifies certificates")
+	}
+
+	if _, err := npmrcTransport(map[string]string{"cafile": filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Errorf("npmrcTransport() with missing cafile should fail")
+	}
+}
+
+func TestRegistryClientRetriesServerErrors(t *testing.T) {
+	original := networkRetry
+	defer func() { networkRetry = original }()
+	networkRetry = newRetryPolicy(2)
+	networkRetry.sleep = func(time.Duration) {}
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		case requests[r.URL.Path] == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			if _, err := fmt.Fprint(w, testNpmPackageDoc); err != nil {
+				t.Errorf("failed to write response: %s", err)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client := newTestRegistryClient(t, server.URL, nil)
+	if _, err := client.packageMetadata("npm"); err != nil {
+		t.Errorf("packageMetadata() error = %v, want succeeded on retry", err)
+	}
+	if _, err := client.packageMetadata("missing"); err == nil {
+		t.Errorf("packageMetadata() should fail for a missing package")
+	}
+	if _, err := client.packageMetadata("down"); err == nil {
+		t.Errorf("packageMetadata() should fail after the retries are used up")
+	}
+
+	want := map[string]int{"/npm": 2, "/missing": 1, "/down": 3}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
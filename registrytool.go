@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/errorutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// commandRunner runs the command and returns its trimmed combined output
+type commandRunner func(name string, args ...string) (string, error)
+
+func runCommandAndReturnOutput(name string, args ...string) (string, error) {
+	return command.New(name, args...).RunAndReturnTrimmedCombinedOutput()
+}
+
+// registryToolInstaller installs the packages published to the registry (npm, yarn, pnpm) with npm
+type registryToolInstaller struct {
+	registry registryClient
+	tools    toolCache
+	run      commandRunner
+}
+
+// installRegistryTool installs the version of a package published to the registry from the tarball
+// kept in the tool cache, downloading it if needed.
+// In isolated mode the package is installed into the tool cache and put first on PATH, otherwise it is installed globally.
+func installRegistryTool(registry registryClient, tools toolCache, name string, req versionRequirement, ver string, isolated bool) error {
+	i := registryToolInstaller{registry: registry, tools: tools, run: runCommandAndReturnOutput}
+	return i.install(name, req, ver, isolated)
+}
+
+func (i registryToolInstaller) install(name string, req versionRequirement, ver string, isolated bool) error {
+	dir := i.tools.path(name, ver)
+	prefix := ""
+	if isolated {
+		prefix = dir
+	}
+
+	if !isolated || !i.tools.isComplete(name, ver) {
+		tarball := filepath.Join(dir, tarballFileName(name, ver))
+		exists, err := pathutil.IsPathExists(tarball)
+		if err != nil {
+			return fmt.Errorf("failed to check if %s exists: %s", tarball, err)
+		}
+		if exists {
+			log.Printf("Using cached %s", tarball)
+		} else {
+			if _, err := i.tools.prepare(name, ver); err != nil {
+				return err
+			}
+			if tarball, err = i.registry.downloadTarball(name, ver, dir); err != nil {
+				return err
+			}
+		}
+
+		if req.Hash != "" && req.Spec == ver {
+			if err := verifyFileHash(tarball, req.HashAlgorithm, req.Hash); err != nil {
+				// the tarball is not reused from the tool cache by the next builds
+				if rmErr := os.Remove(tarball); rmErr != nil {
+					log.Warnf("Failed to remove %s: %s", tarball, rmErr)
+				}
+				return fmt.Errorf("packageManager hash verification failed: %s", err)
+			}
+			log.Printf("Verified %s hash of %s", req.HashAlgorithm, filepath.Base(tarball))
+		}
+
+		if err := i.installNpm(tarball, prefix); err != nil {
+			return err
+		}
+		if !isolated {
+			return nil
+		}
+		if err := i.tools.markComplete(name, ver); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Using cached %s %s from %s", name, ver, dir)
+	}
+
+	binDir := filepath.Join(prefix, "bin")
+	if err := prependPath(binDir); err != nil {
+		return fmt.Errorf("failed to add %s to PATH: %s", binDir, err)
+	}
+	log.Printf("Added %s to PATH", binDir)
+	return nil
+}
+
+// installNpm installs the package with npm globally, or into the prefix, retrying on transient network errors
+func (i registryToolInstaller) installNpm(pkg, prefix string) error {
+	args := []string{"install", "-g", "--force", pkg}
+	if prefix != "" {
+		// installs into <prefix>/lib/node_modules and links the executables into <prefix>/bin,
+		// the system npm is left untouched
+		args = []string{"install", "-g", "--prefix", prefix, pkg}
+	}
+
+	cmdLine := command.New("npm", args...).PrintableCommandArgs()
+	var out string
+	if err := networkRetry.run(cmdLine, func() (string, error) {
+		log.Donef("$ %s", cmdLine)
+		var err error
+		out, err = i.run("npm", args...)
+		return out, err
+	}); err != nil {
+		if errorutil.IsExitStatusError(err) {
+			return fmt.Errorf("npm command failed: %s", out)
+		}
+		return fmt.Errorf("error running npm command: %s", err)
+	}
+
+	return nil
+}
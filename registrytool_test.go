@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// fakeRunner records the commands instead of running them
+type fakeRunner struct {
+	calls [][]string
+	err   error
+}
+
+func (r *fakeRunner) run(name string, args ...string) (string, error) {
+	r.calls = append(r.calls, append([]string{name}, args...))
+	return "", r.err
+}
+
+func restorePath(t *testing.T) func() {
+	original := os.Getenv("PATH")
+	return func() {
+		if err := os.Setenv("PATH", original); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// cacheTarball puts the package tarball into the tool cache, so that it is not downloaded
+func cacheTarball(t *testing.T, tools toolCache, name, ver string) string {
+	dir, err := tools.prepare(name, ver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarball := filepath.Join(dir, tarballFileName(name, ver))
+	if err := ioutil.WriteFile(tarball, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return tarball
+}
+
+func TestRegistryToolInstallerGlobal(t *testing.T) {
+	defer restorePath(t)()
+	tools := toolCache{root: t.TempDir()}
+	tarball := cacheTarball(t, tools, "npm", "10.2.4")
+	runner := &fakeRunner{}
+	path := os.Getenv("PATH")
+
+	i := registryToolInstaller{tools: tools, run: runner.run}
+	if err := i.install("npm", versionRequirement{Spec: "10.2.4"}, "10.2.4", false); err != nil {
+		t.Fatalf("install() error = %v", err)
+	}
+
+	want := [][]string{{"npm", "install", "-g", "--force", tarball}}
+	if !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("install() ran %v, want %v", runner.calls, want)
+	}
+	if os.Getenv("PATH") != path {
+		t.Errorf("install() changed PATH in global mode: %s", os.Getenv("PATH"))
+	}
+	if tools.isComplete("npm", "10.2.4") {
+		t.Errorf("install() marked the global install complete in the tool cache")
+	}
+}
+
+func TestRegistryToolInstallerIsolated(t *testing.T) {
+	defer restorePath(t)()
+	tools := toolCache{root: t.TempDir()}
+	tarball := cacheTarball(t, tools, "pnpm", "8.15.1")
+	prefix := tools.path("pnpm", "8.15.1")
+	runner := &fakeRunner{}
+
+	i := registryToolInstaller{tools: tools, run: runner.run}
+	if err := i.install("pnpm", versionRequirement{}, "8.15.1", true); err != nil {
+		t.Fatalf("install() error = %v", err)
+	}
+
+	want := [][]string{{"npm", "install", "-g", "--prefix", prefix, tarball}}
+	if !reflect.DeepEqual(runner.calls, want) {
+		t.Errorf("install() ran %v, want %v", runner.calls, want)
+	}
+	if binDir := filepath.Join(prefix, "bin") + string(os.PathListSeparator); !strings.HasPrefix(os.Getenv("PATH"), binDir) {
+		t.Errorf("PATH = %s, want prefix %s", os.Getenv("PATH"), binDir)
+	}
+	if !tools.isComplete("pnpm", "8.15.1") {
+		t.Errorf("install() did not mark the isolated install complete in the tool cache")
+	}
+
+	// the complete install is reused without running npm
+	runner.calls = nil
+	if err := i.install("pnpm", versionRequirement{}, "8.15.1", true); err != nil {
+		t.Fatalf("install() error = %v", err)
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("install() of a complete version ran %v", runner.calls)
+	}
+	if binDir := filepath.Join(prefix, "bin") + string(os.PathListSeparator); !strings.HasPrefix(os.Getenv("PATH"), binDir) {
+		t.Errorf("PATH = %s, want prefix %s", os.Getenv("PATH"), binDir)
+	}
+}
+
+func TestRegistryToolInstallerNpmFails(t *testing.T) {
+	defer restorePath(t)()
+	tools := toolCache{root: t.TempDir()}
+	cacheTarball(t, tools, "npm", "10.2.4")
+
+	i := registryToolInstaller{tools: tools, run: (&fakeRunner{err: errors.New("npm not found")}).run}
+	if err := i.install("npm", versionRequirement{}, "10.2.4", true); err == nil {
+		t.Fatalf("install() should fail if npm fails")
+	}
+	if tools.isComplete("npm", "10.2.4") {
+		t.Errorf("install() marked the failed install complete in the tool cache")
+	}
+}
+
+func TestRegistryToolInstallerRemovesTarballFailingHashCheck(t *testing.T) {
+	tools := toolCache{root: t.TempDir()}
+	tarball := cacheTarball(t, tools, "npm", "10.2.4")
+	runner := &fakeRunner{}
+
+	req := versionRequirement{Spec: "10.2.4", HashAlgorithm: "sha512", Hash: "abc123", Source: "packageManager"}
+	i := registryToolInstaller{tools: tools, run: runner.run}
+	if err := i.install("npm", req, "10.2.4", false); err == nil {
+		t.Fatalf("install() should fail the hash check")
+	}
+	if exists, err := pathutil.IsPathExists(tarball); err != nil || exists {
+		t.Errorf("the tarball failing the hash check is kept in the tool cache")
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("install() ran %v after the failed hash check", runner.calls)
+	}
+}
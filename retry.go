@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+const (
+	defaultNetworkRetries = 2
+	// retryDelay is the delay before the first retry, it is doubled for every further retry up to maxRetryDelay
+	retryDelay    = 5 * time.Second
+	maxRetryDelay = time.Minute
+	// outputTailSize is the size of the command output kept for classifying the failure, npm prints the error code at the end
+	outputTailSize = 64 * 1024
+)
+
+// transientErrorPatterns match the error codes of the network failures which may succeed on retry:
+// connection resets and timeouts, DNS lookup failures and registry server errors.
+// Permanent failures, like E404, E401, ERESOLVE or a failing script, are not retried.
+// https://docs.npmjs.com/common-errors
+var transientErrorPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^npm (?:ERR!|error) (?:code|errno) (ECONNRESET|ETIMEDOUT|ESOCKETTIMEDOUT|EAI_AGAIN|ECONNREFUSED|EPIPE|E5\d\d)\s*$`),
+	regexp.MustCompile(`(ERR_PNPM_FETCH_5\d\d)\b`),
+	regexp.MustCompile(`ERR_PNPM_META_FETCH_FAIL.*\b(ECONNRESET|ETIMEDOUT|EAI_AGAIN)\b`),
+}
+
+// transientFailure returns the error code if the output of the failed command shows a transient network failure
+func transientFailure(output string) (string, bool) {
+	for _, pattern := range transientErrorPatterns {
+		if m := pattern.FindStringSubmatch(output); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// networkRetry is the retry policy of the npm commands, set from the network_retries input
+var networkRetry = newRetryPolicy(defaultNetworkRetries)
+
+// retryPolicy retries the commands failing with transient network errors, with exponential backoff
+type retryPolicy struct {
+	retries int
+	delay   time.Duration
+	sleep   func(time.Duration)
+}
+
+func newRetryPolicy(retries int) retryPolicy {
+	return retryPolicy{retries: retries, delay: retryDelay, sleep: time.Sleep}
+}
+
+// backoff returns the delay before the retry, the first retry is 1
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := p.delay
+	for i := 1; i < retry && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
+
+// run runs the command by calling fn, which returns the output of the command, until it succeeds,
+// fails with a permanent error or the retries are used up. Returns the error of the last run.
+func (p retryPolicy) run(cmdLine string, fn func() (string, error)) error {
+	return p.retry(cmdLine, func() (string, error) {
+		out, err := fn()
+		if err != nil {
+			code, _ := transientFailure(out)
+			return code, err
+		}
+		return "", nil
+	})
+}
+
+// runHTTP runs the HTTP request by calling fn until it succeeds, fails with a permanent error or the retries are used up.
+// Connection errors and server errors, returned as transientHTTPError, are retried.
+func (p retryPolicy) runHTTP(request string, fn func() error) error {
+	return p.retry(request, func() (string, error) {
+		err := fn()
+		var transient transientHTTPError
+		if errors.As(err, &transient) {
+			return transient.reason, err
+		}
+		return "", err
+	})
+}
+
+// retry calls fn until it succeeds, fails without a transient error code or the retries are used up
+func (p retryPolicy) retry(name string, fn func() (string, error)) error {
+	for retry := 1; ; retry++ {
+		code, err := fn()
+		if err == nil {
+			return nil
+		}
+		if code == "" || retry > p.retries {
+			return err
+		}
+
+		d := p.backoff(retry)
+		log.Warnf("%s failed with transient network error %s, retrying in %s (%d/%d)", name, code, d, retry, p.retries)
+		p.sleep(d)
+	}
+}
+
+// transientHTTPError is a failed HTTP request which may succeed on retry: a connection error or a server error
+type transientHTTPError struct {
+	reason string
+	err    error
+}
+
+func (e transientHTTPError) Error() string {
+	return e.err.Error()
+}
+
+// httpStatusError returns the error of the unexpected response status, server errors are transient
+func httpStatusError(u string, statusCode int) error {
+	err := fmt.Errorf("failed to fetch %s: status code %d", u, statusCode)
+	if statusCode >= 500 {
+		return transientHTTPError{reason: fmt.Sprintf("status code %d", statusCode), err: err}
+	}
+	return err
+}
+
+// tailBuffer keeps the last bytes written to it
+type tailBuffer struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = b.buf[len(b.buf)-b.size:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
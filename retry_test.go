@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTransientFailure(t *testing.T) {
+	testCases := []struct {
+		output   string
+		wantCode string
+		wantOK   bool
+	}{
+		{"npm ERR! code ECONNRESET\nnpm ERR! errno ECONNRESET\nnpm ERR! network aborted", "ECONNRESET", true},
+		{"npm error code ETIMEDOUT\nnpm error network request to https://registry.npmjs.org/react failed", "ETIMEDOUT", true},
+		{"npm ERR! code EAI_AGAIN", "EAI_AGAIN", true},
+		{"npm ERR! code E503\nnpm ERR! 503 Service Unavailable - GET https://registry.npmjs.org/react", "E503", true},
+		{" ERR_PNPM_FETCH_502  GET https://registry.npmjs.org/react: Bad Gateway - 502", "ERR_PNPM_FETCH_502", true},
+		{" ERR_PNPM_META_FETCH_FAIL  GET https://registry.npmjs.org/react: request failed, reason: read ECONNRESET", "ECONNRESET", true},
+		{"npm ERR! code E404\nnpm ERR! 404 Not Found - GET https://registry.npmjs.org/reactt", "", false},
+		{"npm ERR! code ERESOLVE", "", false},
+		{"Error: connect ECONNRESET 127.0.0.1:8080\nnpm ERR! code ELIFECYCLE", "", false},
+		{"", "", false},
+	}
+
+	for _, tc := range testCases {
+		code, ok := transientFailure(tc.output)
+		if code != tc.wantCode || ok != tc.wantOK {
+			t.Errorf("transientFailure(%q) = %s, %v, want %s, %v", tc.output, code, ok, tc.wantCode, tc.wantOK)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := newRetryPolicy(6)
+	var got []time.Duration
+	for retry := 1; retry <= 6; retry++ {
+		got = append(got, p.backoff(retry))
+	}
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("backoff() = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	transient := errors.New("exit status 1")
+	testCases := []struct {
+		name      string
+		retries   int
+		outputs   []string
+		wantRuns  int
+		wantSleep []time.Duration
+		hasE      bool
+	}{
+		{
+			name:     "succeeds",
+			retries:  2,
+			outputs:  []string{""},
+			wantRuns: 1,
+		},
+		{
+			name:      "succeeds after transient failures",
+			retries:   2,
+			outputs:   []string{"npm ERR! code ECONNRESET", "npm ERR! code E503", ""},
+			wantRuns:  3,
+			wantSleep: []time.Duration{5 * time.Second, 10 * time.Second},
+		},
+		{
+			name:      "retries used up",
+			retries:   1,
+			outputs:   []string{"npm ERR! code ETIMEDOUT", "npm ERR! code ETIMEDOUT"},
+			wantRuns:  2,
+			wantSleep: []time.Duration{5 * time.Second},
+			hasE:      true,
+		},
+		{
+			name:     "permanent failure",
+			retries:  2,
+			outputs:  []string{"npm ERR! code E404"},
+			wantRuns: 1,
+			hasE:     true,
+		},
+		{
+			name:     "retries disabled",
+			retries:  0,
+			outputs:  []string{"npm ERR! code ECONNRESET"},
+			wantRuns: 1,
+			hasE:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sleeps []time.Duration
+			p := newRetryPolicy(tc.retries)
+			p.sleep = func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			}
+
+			runs := 0
+			err := p.run("npm install", func() (string, error) {
+				out := tc.outputs[runs]
+				runs++
+				if out == "" {
+					return "", nil
+				}
+				return out, transient
+			})
+			if tc.hasE != (err != nil) {
+				t.Errorf("run() error = %v, want error: %v", err, tc.hasE)
+			}
+			if runs != tc.wantRuns {
+				t.Errorf("run() ran %d times, want %d", runs, tc.wantRuns)
+			}
+			if !reflect.DeepEqual(sleeps, tc.wantSleep) {
+				t.Errorf("run() slept %v, want %v", sleeps, tc.wantSleep)
+			}
+		})
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := newTailBuffer(8)
+	for _, s := range []string{"npm ERR! ", "code ", "E503"} {
+		if _, err := b.Write([]byte(s)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if got := b.String(); got != "ode E503" {
+		t.Errorf("String() = %q, want %q", got, "ode E503")
+	}
+}
+
+func TestRetryPolicyRunHTTP(t *testing.T) {
+	testCases := []struct {
+		name     string
+		errs     []error
+		wantRuns int
+		hasE     bool
+	}{
+		{"succeeds", []error{nil}, 1, false},
+		{"connection error", []error{transientHTTPError{reason: "connection error", err: errors.New("connection reset")}, nil}, 2, false},
+		{"server error", []error{httpStatusError("https://registry.example.com/npm", 503), nil}, 2, false},
+		{"client error", []error{httpStatusError("https://registry.example.com/npm", 404)}, 1, true},
+		{"retries used up", []error{httpStatusError("u", 500), httpStatusError("u", 500), httpStatusError("u", 500)}, 3, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newRetryPolicy(2)
+			p.sleep = func(time.Duration) {}
+
+			runs := 0
+			err := p.runHTTP("GET https://registry.example.com/npm", func() error {
+				err := tc.errs[runs]
+				runs++
+				return err
+			})
+			if tc.hasE != (err != nil) {
+				t.Errorf("runHTTP() error = %v, want error: %v", err, tc.hasE)
+			}
+			if runs != tc.wantRuns {
+				t.Errorf("runHTTP() ran %d times, want %d", runs, tc.wantRuns)
+			}
+		})
+	}
+}
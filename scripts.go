@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// npmRunValueFlags are the npm run flags followed by a value, the value is not the script name
+var npmRunValueFlags = []string{"--prefix", "--script-shell", "--loglevel"}
+
+// scriptTarget returns the package.json script run by the npm command, ok is false if the command does not run a script
+// or the script is not looked up in the package.json of the working directory (workspaces, --if-present).
+func scriptTarget(npmArgs []string) (string, bool) {
+	if len(npmArgs) == 0 {
+		return "", false
+	}
+
+	for _, arg := range npmArgs[1:] {
+		if arg == "--" {
+			break
+		}
+		if arg == "--if-present" || arg == "-w" || arg == "-ws" || arg == "--workspaces" ||
+			arg == "--workspace" || strings.HasPrefix(arg, "--workspace=") || strings.HasPrefix(arg, "--prefix") {
+			return "", false
+		}
+	}
+
+	switch npmArgs[0] {
+	case "test", "t", "tst":
+		return "test", true
+	case "start":
+		return "start", true
+	case "run", "run-script", "rum", "urn":
+		rest := npmArgs[1:]
+		for i := 0; i < len(rest); i++ {
+			arg := rest[i]
+			if arg == "--" {
+				return "", false
+			}
+			if !strings.HasPrefix(arg, "-") {
+				return arg, true
+			}
+			if sliceutil.IsStringInSlice(arg, npmRunValueFlags) {
+				i++
+			}
+		}
+	}
+	// npm run without script name lists the scripts
+	return "", false
+}
+
+// checkScript returns an error listing the available scripts and the closest match if the script is not defined
+func checkScript(scripts map[string]string, script string) error {
+	if _, ok := scripts[script]; ok {
+		return nil
+	}
+	if len(scripts) == 0 {
+		return fmt.Errorf("missing script `%s`: package.json has no scripts", script)
+	}
+
+	var names []string
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msg := fmt.Sprintf("missing script `%s`, available scripts: %s", script, strings.Join(names, ", "))
+	if closest := closestScript(names, script); closest != "" {
+		msg += fmt.Sprintf("; did you mean `%s`?", closest)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// closestScript returns the script name closest to the mistyped one, empty if none of them is close enough
+func closestScript(names []string, script string) string {
+	closest, closestDist := "", -1
+	for _, name := range names {
+		dist := editDistance(strings.ToLower(name), strings.ToLower(script))
+		if closestDist == -1 || dist < closestDist {
+			closest, closestDist = name, dist
+		}
+	}
+
+	maxDist := len(script) / 3
+	if maxDist < 2 {
+		maxDist = 2
+	}
+	if closestDist == -1 || closestDist > maxDist || closestDist >= len(script) {
+		return ""
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance of the strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// checkCommandScripts checks that the scripts run by the commands are defined in the package.json of the working directory.
+// npm start runs `node server.js` without start script, so it is only checked if there is no server.js.
+func checkCommandScripts(workdir string, commands [][]string) error {
+	pth := filepath.Join(workdir, "package.json")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return fmt.Errorf("failed to check if package.json exists: %s", err)
+	}
+	if !exists {
+		return nil
+	}
+
+	var scripts map[string]string
+	loaded := false
+	for _, npmArgs := range commands {
+		script, ok := scriptTarget(npmArgs)
+		if !ok {
+			continue
+		}
+		if script == "start" {
+			if exists, err := pathutil.IsPathExists(filepath.Join(workdir, "server.js")); err == nil && exists {
+				continue
+			}
+		}
+
+		if !loaded {
+			m, err := readPackageJSON(pth)
+			if err != nil {
+				return err
+			}
+			scripts, loaded = m.Scripts, true
+		}
+		if err := checkScript(scripts, script); err != nil {
+			return err
+		}
+	}
+	return nil
+}
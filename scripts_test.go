@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestScriptTarget(t *testing.T) {
+	testCases := []struct {
+		npmArgs    []string
+		wantScript string
+		wantOK     bool
+	}{
+		{[]string{"run", "build"}, "build", true},
+		{[]string{"run-script", "lint", "--", "--fix"}, "lint", true},
+		{[]string{"run", "--silent", "build"}, "build", true},
+		{[]string{"run", "--script-shell", "bash", "build"}, "build", true},
+		{[]string{"test"}, "test", true},
+		{[]string{"t", "--", "--watch=false"}, "test", true},
+		{[]string{"start"}, "start", true},
+		{[]string{"run"}, "", false},
+		{[]string{"run", "build", "--if-present"}, "", false},
+		{[]string{"run", "build", "-w", "packages/app"}, "", false},
+		{[]string{"run", "build", "--workspace=packages/app"}, "", false},
+		{[]string{"install"}, "", false},
+		{[]string{"ci"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		script, ok := scriptTarget(tc.npmArgs)
+		if script != tc.wantScript || ok != tc.wantOK {
+			t.Errorf("scriptTarget(%v) = %s, %v, want %s, %v", tc.npmArgs, script, ok, tc.wantScript, tc.wantOK)
+		}
+	}
+}
+
+func TestCheckScript(t *testing.T) {
+	scripts := map[string]string{"build": "tsc", "lint": "eslint .", "test": "jest", "test:e2e": "detox test"}
+
+	testCases := []struct {
+		script string
+		want   string
+	}{
+		{"build", ""},
+		{"biuld", "missing script `biuld`, available scripts: build, lint, test, test:e2e; did you mean `build`?"},
+		{"test:e2", "missing script `test:e2`, available scripts: build, lint, test, test:e2e; did you mean `test:e2e`?"},
+		{"deploy", "missing script `deploy`, available scripts: build, lint, test, test:e2e"},
+	}
+
+	for _, tc := range testCases {
+		got := ""
+		if err := checkScript(scripts, tc.script); err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("checkScript(%s) = %s, want %s", tc.script, got, tc.want)
+		}
+	}
+
+	if err := checkScript(nil, "build"); err == nil || err.Error() != "missing script `build`: package.json has no scripts" {
+		t.Errorf("checkScript() without scripts = %v", err)
+	}
+}
+
+func TestCheckCommandScripts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		commands [][]string
+		hasE     bool
+	}{
+		{
+			name:     "no package.json",
+			commands: [][]string{{"run", "build"}},
+		},
+		{
+			name:     "defined scripts",
+			files:    map[string]string{"package.json": `{"scripts":{"build":"tsc","test":"jest"}}`},
+			commands: [][]string{{"ci"}, {"run", "build"}, {"test"}},
+		},
+		{
+			name:     "missing script",
+			files:    map[string]string{"package.json": `{"scripts":{"build":"tsc"}}`},
+			commands: [][]string{{"ci"}, {"run", "lint"}},
+			hasE:     true,
+		},
+		{
+			name:     "start runs server.js",
+			files:    map[string]string{"package.json": `{}`, "server.js": ""},
+			commands: [][]string{{"start"}},
+		},
+		{
+			name:     "missing start script",
+			files:    map[string]string{"package.json": `{}`},
+			commands: [][]string{{"start"}},
+			hasE:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeProjectFiles(t, tc.files)
+
+			err := checkCommandScripts(dir, tc.commands)
+			if tc.hasE != (err != nil) {
+				t.Errorf("checkCommandScripts() error = %v, want error: %v", err, tc.hasE)
+			}
+		})
+	}
+}
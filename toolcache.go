@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	toolCacheDirName      = ".bitrise-npm-tools"
+	toolCacheCompleteFile = ".complete"
+)
+
+// toolCache stores the downloaded npm and Node.js distributions by tool and version (<root>/<tool>/<version>),
+// so that they are reused by the subsequent builds
+type toolCache struct {
+	root string
+}
+
+func newToolCache() (toolCache, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return toolCache{}, fmt.Errorf("failed to get home directory: %s", err)
+	}
+	return toolCache{root: filepath.Join(home, toolCacheDirName)}, nil
+}
+
+func (c toolCache) path(tool, version string) string {
+	return filepath.Join(c.root, tool, version)
+}
+
+// isComplete reports whether the tool version was fully installed into the cache
+func (c toolCache) isComplete(tool, version string) bool {
+	exists, err := pathutil.IsPathExists(filepath.Join(c.path(tool, version), toolCacheCompleteFile))
+	return err == nil && exists
+}
+
+// prepare creates the directory of the tool version
+func (c toolCache) prepare(tool, version string) (string, error) {
+	dir := c.path(tool, version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create tool cache directory: %s", err)
+	}
+	return dir, nil
+}
+
+// markComplete marks the tool version as fully installed, incomplete installs are not reused
+func (c toolCache) markComplete(tool, version string) error {
+	return ioutil.WriteFile(filepath.Join(c.path(tool, version), toolCacheCompleteFile), nil, 0644)
+}
+
+// cachedVersions returns the versions of the tool kept in the cache: the fully installed ones and the downloaded registry tarballs
+func (c toolCache) cachedVersions(tool string) []string {
+	entries, err := ioutil.ReadDir(filepath.Join(c.root, tool))
+	if err != nil {
+		return nil
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		ver := entry.Name()
+		tarball := filepath.Join(c.path(tool, ver), tarballFileName(tool, ver))
+		if exists, err := pathutil.IsPathExists(tarball); c.isComplete(tool, ver) || (err == nil && exists) {
+			versions = append(versions, ver)
+		}
+	}
+	return versions
+}
+
+// resolveWithToolCache resolves the version spec of the tool with resolve. If that fails, for example the registry
+// is not reachable, the highest cached version satisfying the range is used instead.
+func resolveWithToolCache(tools toolCache, tool, spec string, resolve func(string) (string, error)) (string, error) {
+	ver, err := resolve(spec)
+	if err == nil {
+		return ver, nil
+	}
+
+	rng, rngErr := parseNpmRange(spec)
+	if rngErr != nil {
+		return "", err
+	}
+	v, ok := rng.maxSatisfying(tools.cachedVersions(tool))
+	if !ok {
+		return "", err
+	}
+	log.Warnf("%s, using the cached %s version %s satisfying `%s`", err, tool, v.Original(), spec)
+	return v.Original(), nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestToolCache(t *testing.T) {
+	tools := toolCache{root: t.TempDir()}
+
+	if got, want := tools.path("npm", "9.8.1"), filepath.Join(tools.root, "npm", "9.8.1"); got != want {
+		t.Errorf("path() = %s, want %s", got, want)
+	}
+
+	if tools.isComplete("npm", "9.8.1") {
+		t.Errorf("isComplete() should be false for a missing version")
+	}
+
+	if _, err := tools.prepare("npm", "9.8.1"); err != nil {
+		t.Fatalf("prepare() returned error: %s", err)
+	}
+	if tools.isComplete("npm", "9.8.1") {
+		t.Errorf("isComplete() should be false before markComplete()")
+	}
+
+	if err := tools.markComplete("npm", "9.8.1"); err != nil {
+		t.Fatalf("markComplete() returned error: %s", err)
+	}
+	if !tools.isComplete("npm", "9.8.1") {
+		t.Errorf("isComplete() should be true after markComplete()")
+	}
+	if tools.isComplete("node", "9.8.1") {
+		t.Errorf("isComplete() should be false for another tool")
+	}
+}
+
+func TestResolveWithToolCache(t *testing.T) {
+	tools := toolCache{root: t.TempDir()}
+	for _, ver := range []string{"8.19.4", "9.8.1", "10.2.4"} {
+		if _, err := tools.prepare("npm", ver); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only downloaded tarballs and complete installs count as cached
+	if err := ioutil.WriteFile(filepath.Join(tools.path("npm", "8.19.4"), tarballFileName("npm", "8.19.4")), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tools.markComplete("npm", "9.8.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	unreachable := func(string) (string, error) {
+		return "", errors.New("failed to fetch registry")
+	}
+
+	testCases := []struct {
+		spec    string
+		resolve func(string) (string, error)
+		want    string
+		hasE    bool
+	}{
+		{"^9.0.0", func(string) (string, error) { return "9.9.4", nil }, "9.9.4", false},
+		{">=8", unreachable, "9.8.1", false},
+		{"^8.0.0", unreachable, "8.19.4", false},
+		{"^10.0.0", unreachable, "", true},
+		{"latest", unreachable, "", true},
+	}
+
+	for _, tc := range testCases {
+		got, err := resolveWithToolCache(tools, "npm", tc.spec, tc.resolve)
+		if tc.hasE != (err != nil) {
+			t.Errorf("resolveWithToolCache(%s) error = %v, want error: %v", tc.spec, err, tc.hasE)
+		}
+		if got != tc.want {
+			t.Errorf("resolveWithToolCache(%s) = %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+}
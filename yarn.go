@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+)
+
+// defaultYarnClassicSpec is used if the project does not require a Yarn version
+const defaultYarnClassicSpec = "1.x"
+
+// yarnClassic runs the command with Yarn 1, installed from the registry like npm
+type yarnClassic struct {
+	toolProvisioner
+	req versionRequirement
+}
+
+// newYarnManager returns the Yarn Berry package manager if the project is configured for Yarn 2 or newer,
+// Yarn Classic otherwise.
+func newYarnManager(p toolProvisioner, strict bool) (packageManager, error) {
+	req, err := packageManagerRequirement(p.workdir, "yarn", strict)
+	if err != nil {
+		return nil, err
+	}
+	classic := yarnClassic{toolProvisioner: p, req: req}
+
+	yarnrc, err := readYarnrcYml(p.workdir)
+	if err != nil {
+		return nil, err
+	}
+	if yarnrc != nil || isYarnBerryVersion(req.Spec) {
+		return newYarnBerry(classic, yarnrc)
+	}
+
+	berryLockfile, err := isYarnBerryLockfile(p.workdir)
+	if err != nil {
+		return nil, err
+	}
+	if berryLockfile {
+		return newYarnBerry(classic, yarnrc)
+	}
+	return classic, nil
+}
+
+func (yarnClassic) Name() string {
+	return "yarn"
+}
+
+func (y yarnClassic) Setup() error {
+	req := y.req
+	if req.Spec == "" {
+		log.Printf("No Yarn version requirement found, using Yarn %s", defaultYarnClassicSpec)
+		req = versionRequirement{Spec: defaultYarnClassicSpec, Source: "default"}
+	}
+	return y.provision("yarn", "yarn", req)
+}
+
+func (yarnClassic) Command(npmArgs []string) []string {
+	return append([]string{"yarn"}, yarnClassicArgs(npmArgs)...)
+}
+
+// Cache marks the Yarn global cache and the offline mirror configured in .yarnrc for caching, with yarn.lock as the indicator
+func (y yarnClassic) Cache() error {
+	lockfile := filepath.Join(y.workdir, "yarn.lock")
+
+	cmd := command.New("yarn", "cache", "dir")
+	cmd.SetDir(y.workdir)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get Yarn cache directory: %s", out)
+	}
+	dirs := []string{out}
+
+	yarnrc, err := readYarnrc(y.workdir)
+	if err != nil {
+		return err
+	}
+	if mirror := yarnrc["yarn-offline-mirror"]; mirror != "" {
+		if !filepath.IsAbs(mirror) {
+			mirror = filepath.Join(y.workdir, mirror)
+		}
+		dirs = append(dirs, mirror)
+	}
+
+	return cacheDependencyPaths(dirs, lockfile)
+}
+
+// npmValueFlags are the npm install flags followed by a value, the value is not a package to add
+var npmValueFlags = []string{"--registry", "--cache", "--prefix", "--tag"}
+
+// yarnClassicArgs translates npm command arguments to the equivalent Yarn 1 arguments.
+// https://classic.yarnpkg.com/en/docs/migrating-from-npm#toc-cli-commands-comparison
+func yarnClassicArgs(npmArgs []string) []string {
+	if len(npmArgs) == 0 {
+		return []string{"install"}
+	}
+
+	cmd, rest := npmArgs[0], npmArgs[1:]
+	switch cmd {
+	case "install", "isntall", "i", "add":
+		var pkgs, flags []string
+		global := false
+		for i := 0; i < len(rest); i++ {
+			arg := rest[i]
+			switch arg {
+			case "-g", "--global":
+				global = true
+			case "-D", "--save-dev":
+				flags = append(flags, "--dev")
+			case "-O", "--save-optional":
+				flags = append(flags, "--optional")
+			case "-E", "--save-exact":
+				flags = append(flags, "--exact")
+			case "-P", "--save-prod", "-S", "--save":
+				// yarn add saves to dependencies by default
+			default:
+				if !strings.HasPrefix(arg, "-") {
+					pkgs = append(pkgs, arg)
+					continue
+				}
+				flags = append(flags, arg)
+				if i+1 < len(rest) && sliceutil.IsStringInSlice(arg, npmValueFlags) {
+					i++
+					flags = append(flags, rest[i])
+				}
+			}
+		}
+
+		switch {
+		case global:
+			return append(append([]string{"global", "add"}, pkgs...), flags...)
+		case len(pkgs) > 0:
+			return append(append([]string{"add"}, pkgs...), flags...)
+		}
+		return append([]string{"install"}, flags...)
+	case "ci":
+		return append([]string{"install", "--frozen-lockfile"}, rest...)
+	case "run", "run-script", "rum", "urn":
+		return append([]string{"run"}, withoutScriptArgsSeparator(rest)...)
+	case "test", "t", "tst":
+		return append([]string{"test"}, rest...)
+	case "uninstall", "remove", "rm", "r", "un":
+		return append([]string{"remove"}, rest...)
+	case "update", "up", "upgrade":
+		return append([]string{"upgrade"}, rest...)
+	}
+	return npmArgs
+}
+
+// parseYarnrc parses the `key value` pairs of a Yarn 1 .yarnrc file
+// https://classic.yarnpkg.com/en/docs/yarnrc
+func parseYarnrc(content string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.IndexAny(line, " \t")
+		if i == -1 {
+			values[strings.Trim(line, `"`)] = "true"
+			continue
+		}
+		values[strings.Trim(line[:i], `"`)] = strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+	}
+	return values
+}
+
+// readYarnrc returns the config of the .yarnrc file in the working directory, if any
+func readYarnrc(workdir string) (map[string]string, error) {
+	pth := filepath.Join(workdir, ".yarnrc")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+	}
+	if !exists {
+		return map[string]string{}, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf(".yarnrc file read error: %s", err)
+	}
+	return parseYarnrc(content), nil
+}
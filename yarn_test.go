@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestYarnClassicArgs(t *testing.T) {
+	testCases := []struct {
+		npmArgs []string
+		want    []string
+	}{
+		{nil, []string{"install"}},
+		{[]string{"install"}, []string{"install"}},
+		{[]string{"i", "--production"}, []string{"install", "--production"}},
+		{[]string{"ci"}, []string{"install", "--frozen-lockfile"}},
+		{[]string{"install", "lodash"}, []string{"add", "lodash"}},
+		{[]string{"install", "-D", "jest", "--save-exact"}, []string{"add", "jest", "--dev", "--exact"}},
+		{[]string{"install", "--save", "lodash", "--registry", "https://registry.example.com"}, []string{"add", "lodash", "--registry", "https://registry.example.com"}},
+		{[]string{"install", "-g", "typescript"}, []string{"global", "add", "typescript"}},
+		{[]string{"run", "build"}, []string{"run", "build"}},
+		{[]string{"run-script", "lint", "--", "--fix"}, []string{"run", "lint", "--fix"}},
+		{[]string{"test"}, []string{"test"}},
+		{[]string{"t", "--coverage"}, []string{"test", "--coverage"}},
+		{[]string{"uninstall", "lodash"}, []string{"remove", "lodash"}},
+		{[]string{"update"}, []string{"upgrade"}},
+		{[]string{"start"}, []string{"start"}},
+		{[]string{"audit"}, []string{"audit"}},
+	}
+
+	for _, tc := range testCases {
+		if got := yarnClassicArgs(tc.npmArgs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("yarnClassicArgs(%v) = %v, want %v", tc.npmArgs, got, tc.want)
+		}
+	}
+}
+
+func TestParseYarnrc(t *testing.T) {
+	content := `# THIS IS AN AUTOGENERATED FILE
+yarn-offline-mirror "./npm-packages-offline-cache"
+yarn-offline-mirror-pruning true
+"--install.frozen-lockfile" true
+registry "https://registry.example.com/"
+`
+	want := map[string]string{
+		"yarn-offline-mirror":         "./npm-packages-offline-cache",
+		"yarn-offline-mirror-pruning": "true",
+		"--install.frozen-lockfile":   "true",
+		"registry":                    "https://registry.example.com/",
+	}
+
+	if got := parseYarnrc(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYarnrc() = %v, want %v", got, want)
+	}
+}
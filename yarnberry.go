@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+const (
+	// yarnBerryPackage is the registry package of the Yarn 2+ releases, the yarn package only has the 1.x releases
+	yarnBerryPackage = "@yarnpkg/cli-dist"
+	// defaultYarnBerrySpec is used if a Yarn Berry project does not require a Yarn version
+	defaultYarnBerrySpec = ">=2"
+	// defaultNodeLinker is the nodeLinker of Yarn Berry if .yarnrc.yml does not set it
+	defaultNodeLinker = "pnp"
+)
+
+// yarnBerry runs the command with Yarn 2 or newer, preferably with the release checked in to the repository (yarnPath)
+type yarnBerry struct {
+	yarnClassic
+	yarnrc map[string]string
+	// release is the absolute path of the checked-in Yarn release, empty if the project does not have one
+	release string
+}
+
+func newYarnBerry(classic yarnClassic, yarnrc map[string]string) (yarnBerry, error) {
+	y := yarnBerry{yarnClassic: classic, yarnrc: yarnrc}
+
+	if yarnPath := yarnrc["yarnPath"]; yarnPath != "" {
+		pth := filepath.Join(classic.workdir, yarnPath)
+		exists, err := pathutil.IsPathExists(pth)
+		if err != nil {
+			return yarnBerry{}, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+		}
+		if exists {
+			y.release = pth
+		} else {
+			log.Warnf("yarnPath %s set in .yarnrc.yml does not exist", yarnPath)
+		}
+	}
+	return y, nil
+}
+
+func (y yarnBerry) Setup() error {
+	if y.release != "" {
+		log.Donef("Using the checked-in Yarn release %s", y.yarnrc["yarnPath"])
+		return nil
+	}
+
+	req := y.req
+	if req.Spec == "" {
+		log.Printf("No Yarn version requirement found, using Yarn %s", defaultYarnBerrySpec)
+		req = versionRequirement{Spec: defaultYarnBerrySpec, Source: "default"}
+	}
+	if req.Hash != "" && !y.corepack {
+		// Corepack hashes the standalone Yarn bundle, not the registry package
+		log.Warnf("The packageManager hash of Yarn Berry can only be verified by Corepack, enable it to verify the hash")
+		req.HashAlgorithm, req.Hash = "", ""
+	}
+	return y.provision("yarn", yarnBerryPackage, req)
+}
+
+// executable returns the command line running Yarn
+func (y yarnBerry) executable() []string {
+	if y.release != "" {
+		return []string{"node", y.release}
+	}
+	return []string{"yarn"}
+}
+
+func (y yarnBerry) Command(npmArgs []string) []string {
+	return append(y.executable(), yarnBerryArgs(npmArgs)...)
+}
+
+// nodeLinker returns how Yarn installs the dependencies: pnp, pnpm or node-modules
+func (y yarnBerry) nodeLinker() string {
+	if linker := y.yarnrc["nodeLinker"]; linker != "" {
+		return linker
+	}
+	return defaultNodeLinker
+}
+
+// Cache marks the Yarn cache folder and the install state for caching, with yarn.lock as the indicator.
+// The install state is the Plug'n'Play loader and the unplugged packages with the pnp linker, node_modules otherwise.
+func (y yarnBerry) Cache() error {
+	args := append(y.executable(), "config", "get", "cacheFolder")
+	cmd := command.New(args[0], args[1:]...)
+	cmd.SetDir(y.workdir)
+	out, err := cmd.RunAndReturnTrimmedCombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to get Yarn cache folder: %s", out)
+	}
+	paths := []string{out, filepath.Join(y.workdir, ".yarn", "install-state.gz")}
+
+	linker := y.nodeLinker()
+	log.Printf("Yarn nodeLinker: %s", linker)
+	if linker == "pnp" {
+		for _, name := range []string{".pnp.cjs", ".pnp.js", ".pnp.loader.mjs", filepath.Join(".yarn", "unplugged")} {
+			paths = append(paths, filepath.Join(y.workdir, name))
+		}
+	} else {
+		paths = append(paths, filepath.Join(y.workdir, "node_modules"))
+	}
+
+	return cacheDependencyPaths(paths, filepath.Join(y.workdir, "yarn.lock"))
+}
+
+// isYarnBerryVersion reports whether the Yarn version requirement only allows Yarn 2 or newer,
+// for example 4.0.2 or >=3: the lowest version satisfying it is 2.0.0 or newer.
+func isYarnBerryVersion(spec string) bool {
+	if spec == "" {
+		return false
+	}
+	r, err := parseNpmRange(spec)
+	if err != nil {
+		return false
+	}
+	v, ok := r.minVersion()
+	return ok && v.Segments()[0] >= 2
+}
+
+// yarnBerryLockfileRegexp matches the __metadata header Yarn 2+ writes into yarn.lock, Yarn 1 lockfiles do not have it
+var yarnBerryLockfileRegexp = regexp.MustCompile(`(?m)^__metadata:`)
+
+// isYarnBerryLockfile reports whether the yarn.lock of the project was written by Yarn 2 or newer
+func isYarnBerryLockfile(workdir string) (bool, error) {
+	pth := filepath.Join(workdir, "yarn.lock")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return false, fmt.Errorf("yarn.lock file read error: %s", err)
+	}
+	return yarnBerryLockfileRegexp.MatchString(content), nil
+}
+
+// yarnBerryArgs translates npm command arguments to the equivalent Yarn 2+ arguments.
+// https://yarnpkg.com/cli
+func yarnBerryArgs(npmArgs []string) []string {
+	args := yarnClassicArgs(npmArgs)
+	switch {
+	case len(args) > 1 && args[0] == "install" && args[1] == "--frozen-lockfile":
+		return append([]string{"install", "--immutable"}, args[2:]...)
+	case len(args) > 0 && args[0] == "upgrade":
+		return append([]string{"up"}, args[1:]...)
+	}
+	return args
+}
+
+// parseYarnrcYml parses the top level scalar settings of a .yarnrc.yml file, nested settings are skipped
+// https://yarnpkg.com/configuration/yarnrc
+func parseYarnrcYml(content string) map[string]string {
+	values := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		if j := strings.Index(value, " #"); j != -1 {
+			value = strings.TrimSpace(value[:j])
+		}
+		values[strings.TrimSpace(line[:i])] = strings.Trim(value, `"'`)
+	}
+	return values
+}
+
+// readYarnrcYml returns the config of the .yarnrc.yml file in the working directory, nil if there is none
+func readYarnrcYml(workdir string) (map[string]string, error) {
+	pth := filepath.Join(workdir, ".yarnrc.yml")
+	exists, err := pathutil.IsPathExists(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if %s exists: %s", pth, err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	content, err := fileutil.ReadStringFromFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf(".yarnrc.yml file read error: %s", err)
+	}
+	return parseYarnrcYml(content), nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestYarnBerryArgs(t *testing.T) {
+	testCases := []struct {
+		npmArgs []string
+		want    []string
+	}{
+		{nil, []string{"install"}},
+		{[]string{"ci"}, []string{"install", "--immutable"}},
+		{[]string{"install", "-D", "jest"}, []string{"add", "jest", "--dev"}},
+		{[]string{"update", "lodash"}, []string{"up", "lodash"}},
+		{[]string{"run", "build"}, []string{"run", "build"}},
+	}
+
+	for _, tc := range testCases {
+		if got := yarnBerryArgs(tc.npmArgs); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("yarnBerryArgs(%v) = %v, want %v", tc.npmArgs, got, tc.want)
+		}
+	}
+}
+
+func TestParseYarnrcYml(t *testing.T) {
+	content := `# comment
+yarnPath: .yarn/releases/yarn-3.6.4.cjs
+nodeLinker: "node-modules" # inline comment
+enableGlobalCache: false
+packageExtensions:
+  "debug@*":
+    dependencies:
+      supports-color: "*"
+`
+	want := map[string]string{
+		"yarnPath":          ".yarn/releases/yarn-3.6.4.cjs",
+		"nodeLinker":        "node-modules",
+		"enableGlobalCache": "false",
+		"packageExtensions": "",
+	}
+
+	if got := parseYarnrcYml(content); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYarnrcYml() = %v, want %v", got, want)
+	}
+}
+
+func TestIsYarnBerryVersion(t *testing.T) {
+	testCases := []struct {
+		spec string
+		want bool
+	}{
+		{"", false},
+		{"1.22.19", false},
+		{"3.6.4", true},
+		{"4.0.2", true},
+		{"^3.0.0", true},
+		{">=3", true},
+		{"2.x", true},
+		{">=1", false},
+		{"^1.22.0", false},
+		{"1.x || 3.x", false},
+		{"*", false},
+		{"invalid", false},
+	}
+
+	for _, tc := range testCases {
+		if got := isYarnBerryVersion(tc.spec); got != tc.want {
+			t.Errorf("isYarnBerryVersion(%s) = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestNewYarnManager(t *testing.T) {
+	testCases := []struct {
+		files       map[string]string
+		wantBerry   bool
+		wantRelease string
+	}{
+		{map[string]string{"yarn.lock": ``}, false, ""},
+		{map[string]string{"package.json": `{"packageManager": "yarn@1.22.19"}`}, false, ""},
+		{map[string]string{"package.json": `{"packageManager": "yarn@4.0.2"}`}, true, ""},
+		{map[string]string{"package.json": `{"engines": {"yarn": ">=3"}}`}, true, ""},
+		{map[string]string{"package.json": `{"engines": {"yarn": "^1.22.0"}}`}, false, ""},
+		{map[string]string{"yarn.lock": "# This file is generated by running \"yarn install\" inside your project.\n\n__metadata:\n  version: 8\n"}, true, ""},
+		{map[string]string{"yarn.lock": "# yarn lockfile v1\n\n\nleft-pad@1.3.0:\n  version \"1.3.0\"\n"}, false, ""},
+		{map[string]string{".yarnrc.yml": `nodeLinker: pnp`}, true, ""},
+		{map[string]string{".yarnrc.yml": `yarnPath: yarn-3.6.4.cjs`, "yarn-3.6.4.cjs": ``}, true, "yarn-3.6.4.cjs"},
+		{map[string]string{".yarnrc.yml": `yarnPath: missing.cjs`}, true, ""},
+	}
+
+	for _, tc := range testCases {
+		dir := writeProjectFiles(t, tc.files)
+
+		pm, err := newYarnManager(toolProvisioner{workdir: dir}, false)
+		if err != nil {
+			t.Fatalf("newYarnManager(%v) error = %v", tc.files, err)
+		}
+		berry, isBerry := pm.(yarnBerry)
+		if isBerry != tc.wantBerry {
+			t.Errorf("newYarnManager(%v) = %T, want Yarn Berry: %v", tc.files, pm, tc.wantBerry)
+			continue
+		}
+		if wantRelease := tc.wantRelease; isBerry && wantRelease != "" {
+			wantRelease = filepath.Join(dir, wantRelease)
+			if berry.release != wantRelease {
+				t.Errorf("newYarnManager(%v) release = %s, want %s", tc.files, berry.release, wantRelease)
+			}
+		} else if isBerry && berry.release != "" {
+			t.Errorf("newYarnManager(%v) release = %s, want none", tc.files, berry.release)
+		}
+	}
+}